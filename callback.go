@@ -0,0 +1,152 @@
+package gomigrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// CallbackEvent 与Flyway的回调事件名称一致, 也是回调SQL文件的文件名前缀
+type CallbackEvent string
+
+const (
+	BeforeMigrate         CallbackEvent = "beforeMigrate"
+	BeforeEachMigrate     CallbackEvent = "beforeEachMigrate"
+	AfterEachMigrate      CallbackEvent = "afterEachMigrate"
+	AfterEachMigrateError CallbackEvent = "afterEachMigrateError"
+	AfterMigrate          CallbackEvent = "afterMigrate"
+	AfterMigrateError     CallbackEvent = "afterMigrateError"
+	BeforeUndo            CallbackEvent = "beforeUndo"
+	BeforeEachUndo        CallbackEvent = "beforeEachUndo"
+	AfterEachUndo         CallbackEvent = "afterEachUndo"
+	AfterEachUndoError    CallbackEvent = "afterEachUndoError"
+	AfterUndo             CallbackEvent = "afterUndo"
+	AfterUndoError        CallbackEvent = "afterUndoError"
+	BeforeBaseline        CallbackEvent = "beforeBaseline"
+	AfterBaseline         CallbackEvent = "afterBaseline"
+	AfterBaselineError    CallbackEvent = "afterBaselineError"
+	BeforeRepair          CallbackEvent = "beforeRepair"
+	AfterRepair           CallbackEvent = "afterRepair"
+	AfterRepairError      CallbackEvent = "afterRepairError"
+	BeforeInfo            CallbackEvent = "beforeInfo"
+	AfterInfo             CallbackEvent = "afterInfo"
+	AfterInfoError        CallbackEvent = "afterInfoError"
+)
+
+var callbackEvents = []CallbackEvent{
+	BeforeMigrate, BeforeEachMigrate, AfterEachMigrate, AfterEachMigrateError, AfterMigrate, AfterMigrateError,
+	BeforeUndo, BeforeEachUndo, AfterEachUndo, AfterEachUndoError, AfterUndo, AfterUndoError,
+	BeforeBaseline, AfterBaseline, AfterBaselineError,
+	BeforeRepair, AfterRepair, AfterRepairError,
+	BeforeInfo, AfterInfo, AfterInfoError,
+}
+
+var reFlywayCallbackFilename = regexp.MustCompile("^([a-zA-Z]+)(__.+)?\\.sql$")
+
+type CallbackInfo struct {
+	Event CallbackEvent
+	// Migration 为each事件对应的Migration, 其他事件为nil
+	Migration *Migration
+	// Err 为Error事件对应的错误
+	Err error
+}
+
+// Callback 在与Migration相同的数据库连接上执行, 持有Migration锁时也在锁内执行.
+// 返回错误会使操作失败, Error事件的回调返回的错误被忽略, 操作仍返回原来的错误
+type Callback func(ctx context.Context, conn *sql.Conn, info *CallbackInfo) error
+
+// CallbackScript 是Flyway风格的回调SQL文件, 如beforeMigrate.sql, afterMigrate__refresh_grants.sql
+type CallbackScript struct {
+	Event   CallbackEvent
+	Name    string
+	Content string
+}
+
+func (s CallbackScript) callback() Callback {
+	return func(ctx context.Context, conn *sql.Conn, info *CallbackInfo) error {
+		if execErr := execSQLStatements(ctx, conn, s.Name, s.Content, 1); execErr != nil {
+			return execErr
+		}
+		return nil
+	}
+}
+
+// parseFlywayCallbackFilename 解析回调SQL文件名, 不是回调文件时返回空
+func parseFlywayCallbackFilename(filename string) CallbackEvent {
+	matches := reFlywayCallbackFilename.FindStringSubmatch(filename)
+	if len(matches) == 0 {
+		return ""
+	}
+	for _, event := range callbackEvents {
+		if string(event) == matches[1] {
+			return event
+		}
+	}
+	return ""
+}
+
+// GetCallbacksFromFlywayDir 读取目录中的回调SQL文件, 同一事件的多个文件按文件名顺序执行
+func GetCallbacksFromFlywayDir(sourcePath string) ([]CallbackScript, error) {
+	return GetCallbacksFromFlywayFS(os.DirFS(sourcePath), ".", FlywayLoadOptions{})
+}
+
+func GetCallbacksFromFlywayEmbedFS(embedFS embed.FS, subDirPath string) ([]CallbackScript, error) {
+	return GetCallbacksFromFlywayFS(embedFS, subDirPath, FlywayLoadOptions{})
+}
+
+// GetCallbacksFromFlywayFS 与GetMigrationsFromFlywayFS相同地遍历fsys, 读取其中的回调SQL文件
+func GetCallbacksFromFlywayFS(fsys fs.FS, root string, options FlywayLoadOptions) ([]CallbackScript, error) {
+	scripts := make([]CallbackScript, 0)
+	err := walkFlywayFS(fsys, root, options, func(filePath string) error {
+		event := parseFlywayCallbackFilename(path.Base(filePath))
+		if event == "" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		scripts = append(scripts, CallbackScript{Event: event, Name: path.Base(filePath), Content: string(content)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(scripts, func(i, j int) bool {
+		return scripts[i].Name < scripts[j].Name
+	})
+	return scripts, nil
+}
+
+// runCallbacks 按注册顺序执行事件的回调
+func (b *BaseExecutor) runCallbacks(ctx context.Context, conn *sql.Conn, info *CallbackInfo) error {
+	callbacks := b.callbacks[info.Event]
+	if len(callbacks) > 0 {
+		b.getLogger().Debug("running callbacks", "event", info.Event, "count", len(callbacks))
+	}
+	for _, callback := range callbacks {
+		if err := callback(ctx, conn, info); err != nil {
+			b.getLogger().Error("callback failed", "event", info.Event, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// withCallbacks 在op前后执行before和after回调, op失败时执行afterError回调
+func (b *BaseExecutor) withCallbacks(ctx context.Context, conn *sql.Conn, before, after, afterError CallbackEvent, migration *Migration, op func() error) error {
+	if err := b.runCallbacks(ctx, conn, &CallbackInfo{Event: before, Migration: migration}); err != nil {
+		return err
+	}
+	if err := op(); err != nil {
+		// op失败可能是因为ctx已取消, 仍然执行afterError回调
+		_ = b.runCallbacks(context.Background(), conn, &CallbackInfo{Event: afterError, Migration: migration, Err: err})
+		return err
+	}
+	return b.runCallbacks(ctx, conn, &CallbackInfo{Event: after, Migration: migration})
+}
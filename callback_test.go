@@ -0,0 +1,25 @@
+package gomigrate
+
+import "testing"
+
+func TestGetCallbacksFromFlywayEmbedFS(t *testing.T) {
+	scripts, err := GetCallbacksFromFlywayEmbedFS(testdataFS, "testdata/embed_flyway/callbacks")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := []CallbackScript{
+		{Event: AfterMigrate, Name: "afterMigrate.sql", Content: "insert into test_callback values('afterMigrate')"},
+		{Event: AfterMigrate, Name: "afterMigrate__record.sql", Content: "insert into test_callback values('afterMigrate__record')"},
+		{Event: BeforeMigrate, Name: "beforeMigrate.sql", Content: "create table if not exists test_callback(event varchar(64) not null)"},
+	}
+	if len(scripts) != len(expected) {
+		t.Errorf("expect %d callback scripts, got %v", len(expected), scripts)
+		t.FailNow()
+	}
+	for i := range expected {
+		if scripts[i] != expected[i] {
+			t.Errorf("expect %v, got %v", expected[i], scripts[i])
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/farseer810/gomigrate"
+)
+
+// exitCode 将错误映射为退出码, 供CI和Kubernetes init容器区分失败的原因
+func exitCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, gomigrate.ErrMigrationMissing):
+		return exitMigrationMissing
+	case errors.Is(err, gomigrate.ErrMigrationModified):
+		return exitMigrationModified
+	case errors.Is(err, gomigrate.ErrBrokenSchemaHistory):
+		return exitBrokenSchemaHistory
+	case errors.Is(err, gomigrate.ErrMigrationFailed):
+		return exitMigrationFailed
+	case errors.Is(err, gomigrate.ErrLockTimeout):
+		return exitLockTimeout
+	case errors.Is(err, gomigrate.ErrInvalidMigrations), errors.Is(err, gomigrate.ErrDuplicatedMigrationName),
+		errors.Is(err, gomigrate.ErrDuplicatedMigrationVersion):
+		return exitInvalidMigrations
+	default:
+		return exitError
+	}
+}
+
+// openExecutor 连接数据库并加载目录中的Migration和回调SQL文件
+func (c *config) openExecutor(options ...gomigrate.Option) (gomigrate.MigrationExecutor, error) {
+	if c.dsn == "" {
+		fmt.Fprintln(c.stderr, "missing -dsn or GOMIGRATE_DSN")
+		return nil, errUsage
+	}
+	migrations, warnings, err := gomigrate.LoadFlywayMigrations(os.DirFS(c.dir), ".", gomigrate.FlywayLoadOptions{Strict: c.strict})
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(c.stderr, "warning: %s\n", warning)
+	}
+	callbackScripts, err := gomigrate.GetCallbacksFromFlywayDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options, gomigrate.WithOutput(c.stdout), gomigrate.WithCallbackScripts(callbackScripts))
+	if c.table != "" {
+		options = append(options, gomigrate.WithSchemaHistoryTableName(c.table))
+	}
+	if c.flyway {
+		options = append(options, gomigrate.WithFlywaySchemaHistory())
+	}
+	if c.lockTimeout > 0 {
+		options = append(options, gomigrate.WithLockTimeout(c.lockTimeout))
+	}
+	if c.verbose {
+		options = append(options, gomigrate.WithLogger(gomigrate.NewStdLogger(log.New(c.stderr, "", log.LstdFlags), true)))
+	}
+	executor, err := gomigrate.OpenMySQLMigrateExecutor(c.dsn, options...)
+	if err != nil {
+		return nil, err
+	}
+	executor.SetMigrations(migrations)
+	return executor, nil
+}
+
+func runInfo(c *config, args []string) error {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	format := flags.String("format", envString("GOMIGRATE_FORMAT", string(gomigrate.OutputTable)), "output format: table, json, yaml, markdown or csv [GOMIGRATE_FORMAT]")
+	details := flags.Bool("details", false, "show execution time, installed by, app version and success")
+	target := flags.String("target", "", "show migrations after this name or version as ABOVE TARGET")
+	if err := parseCommandFlags(c, flags, args, 0); err != nil {
+		return err
+	}
+	outputFormat, err := gomigrate.ParseOutputFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	options := []gomigrate.Option{gomigrate.WithOutputFormat(outputFormat)}
+	if *details {
+		options = append(options, gomigrate.WithHistoryDetails())
+	}
+	if *target != "" {
+		options = append(options, gomigrate.WithTarget(*target))
+	}
+	executor, err := c.openExecutor(options...)
+	if err != nil {
+		return err
+	}
+	defer executor.Close()
+	return executor.ShowMigrations()
+}
+
+func runMigrate(c *config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	target := flags.String("target", os.Getenv("GOMIGRATE_TARGET"), "install up to this migration name or version [GOMIGRATE_TARGET]")
+	outOfOrder := flags.Bool("out-of-order", envBool("GOMIGRATE_OUT_OF_ORDER"), "install migrations lower than installed ones [GOMIGRATE_OUT_OF_ORDER]")
+	if err := parseCommandFlags(c, flags, args, 0); err != nil {
+		return err
+	}
+
+	options := make([]gomigrate.Option, 0)
+	if *outOfOrder {
+		options = append(options, gomigrate.WithOutOfOrder())
+	}
+	executor, err := c.openExecutor(options...)
+	if err != nil {
+		return err
+	}
+	defer executor.Close()
+	if *target != "" {
+		return executor.InstallMigrationsTo(*target)
+	}
+	return executor.InstallMigrations()
+}
+
+func runValidate(c *config, args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	outOfOrder := flags.Bool("out-of-order", envBool("GOMIGRATE_OUT_OF_ORDER"), "match installed migrations by name or version [GOMIGRATE_OUT_OF_ORDER]")
+	if err := parseCommandFlags(c, flags, args, 0); err != nil {
+		return err
+	}
+
+	options := make([]gomigrate.Option, 0)
+	if *outOfOrder {
+		options = append(options, gomigrate.WithOutOfOrder())
+	}
+	executor, err := c.openExecutor(options...)
+	if err != nil {
+		return err
+	}
+	defer executor.Close()
+	if err = executor.CheckMigrations(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "migrations are valid")
+	return nil
+}
+
+func runBaseline(c *config, args []string) error {
+	flags := flag.NewFlagSet("baseline", flag.ContinueOnError)
+	version := flags.String("version", "1", "baseline version, migrations up to it are treated as installed")
+	description := flags.String("description", "", "baseline description")
+	if err := parseCommandFlags(c, flags, args, 0); err != nil {
+		return err
+	}
+
+	executor, err := c.openExecutor()
+	if err != nil {
+		return err
+	}
+	defer executor.Close()
+	return executor.Baseline(*version, *description)
+}
+
+func runRepair(c *config, args []string) error {
+	flags := flag.NewFlagSet("repair", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only print the repair report")
+	accept := flags.String("accept-modified", "", "comma separated names or versions of modified migrations to accept")
+	if err := parseCommandFlags(c, flags, args, 0); err != nil {
+		return err
+	}
+
+	options := gomigrate.RepairOptions{DryRun: *dryRun}
+	if *accept != "" {
+		options.AcceptModified = strings.Split(*accept, ",")
+	}
+	executor, err := c.openExecutor()
+	if err != nil {
+		return err
+	}
+	defer executor.Close()
+	_, err = executor.Repair(options)
+	return err
+}
+
+func runNew(c *config, args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	bump := flags.String("bump", envString("GOMIGRATE_BUMP", string(gomigrate.BumpMajor)), "next version: major, minor or timestamp [GOMIGRATE_BUMP]")
+	undo := flags.Bool("undo", false, "also create an undo file")
+	if err := parseCommandFlags(c, flags, args, 1); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "usage: gomigrate new [-bump major|minor|timestamp] [-undo] <description>")
+		return errUsage
+	}
+
+	path, err := gomigrate.NewFlywayMigrationFile(c.dir, flags.Arg(0), gomigrate.VersionBump(*bump))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, path)
+	if *undo {
+		undoPath, err := gomigrate.NewFlywayUndoFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, undoPath)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Command gomigrate 使用Flyway风格的Migration目录管理MySQL数据库.
+//
+//	gomigrate [flags] <info|migrate|validate|baseline|repair|new> [command flags]
+//
+// 连接和目录可以通过参数或环境变量指定, 参数优先:
+//
+//	-dsn           GOMIGRATE_DSN            MySQL DSN, 如 user:password@tcp(127.0.0.1:3306)/app
+//	-dir           GOMIGRATE_DIR            Migration目录, 默认为migrations
+//	-table         GOMIGRATE_TABLE          Schema History表名
+//	-flyway        GOMIGRATE_FLYWAY         使用Flyway格式的Schema History表
+//	-lock-timeout  GOMIGRATE_LOCK_TIMEOUT   等待Migration锁的时间
+//	-verbose       GOMIGRATE_VERBOSE        在stderr输出日志
+//	-strict        GOMIGRATE_STRICT         Migration目录中有重复版本号或无效文件名时失败, 否则只输出警告
+//
+// 不同的错误使用不同的退出码:
+//
+//	0 成功, 1 其他错误, 2 参数错误, 3 MIGRATION MISSING, 4 MIGRATION MODIFIED,
+//	5 SCHEMA BROKEN, 6 Migration执行失败, 7 等待锁超时, 8 其他无效的Migration
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitMigrationMissing
+	exitMigrationModified
+	exitBrokenSchemaHistory
+	exitMigrationFailed
+	exitLockTimeout
+	exitInvalidMigrations
+)
+
+var errUsage = errors.New("usage error")
+
+type command struct {
+	name  string
+	usage string
+	run   func(c *config, args []string) error
+}
+
+var commands = []command{
+	{"info", "show the status of all migrations", runInfo},
+	{"migrate", "install pending migrations", runMigrate},
+	{"validate", "check installed migrations against the migrations directory", runValidate},
+	{"baseline", "baseline an existing database", runBaseline},
+	{"repair", "repair the schema history table", runRepair},
+	{"new", "create a new migration file with the next version", runNew},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	c := &config{stdout: stdout, stderr: stderr}
+	flags := flag.NewFlagSet("gomigrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	c.registerFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gomigrate [flags] <command> [command flags]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, cmd := range commands {
+			fmt.Fprintf(stderr, "  %-9s %s\n", cmd.name, cmd.usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if c.dsn == "" {
+		c.dsn = os.Getenv("GOMIGRATE_DSN")
+	}
+
+	name := flags.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(c, flags.Args()[1:])
+		if err != nil && !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "gomigrate %s: %s\n", name, err)
+		}
+		return exitCode(err)
+	}
+	fmt.Fprintf(stderr, "gomigrate: unknown command %q\n", name)
+	flags.Usage()
+	return exitUsage
+}
+
+type config struct {
+	dsn         string
+	dir         string
+	table       string
+	flyway      bool
+	lockTimeout time.Duration
+	verbose     bool
+	strict      bool
+	stdout      io.Writer
+	stderr      io.Writer
+}
+
+func (c *config) registerFlags(flags *flag.FlagSet) {
+	// DSN包含密码, 不能作为默认值出现在用法说明中, 解析参数后再读取环境变量
+	flags.StringVar(&c.dsn, "dsn", "", "MySQL DSN [GOMIGRATE_DSN]")
+	flags.StringVar(&c.dir, "dir", envString("GOMIGRATE_DIR", "migrations"), "flyway style migrations directory [GOMIGRATE_DIR]")
+	flags.StringVar(&c.table, "table", os.Getenv("GOMIGRATE_TABLE"), "schema history table name [GOMIGRATE_TABLE]")
+	flags.BoolVar(&c.flyway, "flyway", envBool("GOMIGRATE_FLYWAY"), "use a flyway schema history table [GOMIGRATE_FLYWAY]")
+	flags.DurationVar(&c.lockTimeout, "lock-timeout", envDuration("GOMIGRATE_LOCK_TIMEOUT"), "how long to wait for the migration lock [GOMIGRATE_LOCK_TIMEOUT]")
+	flags.BoolVar(&c.verbose, "verbose", envBool("GOMIGRATE_VERBOSE"), "log each step to stderr [GOMIGRATE_VERBOSE]")
+	flags.BoolVar(&c.strict, "strict", envBool("GOMIGRATE_STRICT"), "fail on duplicated versions and invalid file names instead of warning [GOMIGRATE_STRICT]")
+}
+
+func envString(key string, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// envBool 和envDuration 无法解析时使用零值, 与未设置相同
+func envBool(key string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(key))
+	return value
+}
+
+func envDuration(key string) time.Duration {
+	value, _ := time.ParseDuration(os.Getenv(key))
+	return value
+}
+
+// parseCommandFlags 解析子命令的参数, 子命令不接受多余的位置参数时maxArgs为0
+func parseCommandFlags(c *config, flags *flag.FlagSet, args []string, maxArgs int) error {
+	flags.SetOutput(c.stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if flags.NArg() > maxArgs {
+		fmt.Fprintf(c.stderr, "unexpected arguments: %s\n", strings.Join(flags.Args()[maxArgs:], " "))
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/farseer810/gomigrate"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const mysqlTestSource = "root:123456@tcp(127.0.0.1:3306)/gomigrate_test?charset=utf8"
+
+func TestExitCode(t *testing.T) {
+	cases := map[error]int{
+		nil:                                  exitOK,
+		errors.New("connection refused"):     exitError,
+		errUsage:                             exitUsage,
+		gomigrate.ErrMigrationMissing:        exitMigrationMissing,
+		gomigrate.ErrMigrationModified:       exitMigrationModified,
+		gomigrate.ErrBrokenSchemaHistory:     exitBrokenSchemaHistory,
+		gomigrate.ErrLockTimeout:             exitLockTimeout,
+		gomigrate.ErrDuplicatedMigrationName: exitInvalidMigrations,
+		gomigrate.ErrInvalidMigrations:       exitInvalidMigrations,
+		&gomigrate.MigrationError{Name: "V1__test.sql", Err: errors.New("syntax error")}: exitMigrationFailed,
+		fmt.Errorf("%w: V1__test.sql", gomigrate.ErrMigrationModified):                   exitMigrationModified,
+	}
+	for err, code := range cases {
+		if exitCode(err) != code {
+			t.Errorf("expect exit code %d for %v, got %d", code, err, exitCode(err))
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"unknown"}, stdout, stderr); code != exitUsage {
+		t.Errorf("expect exit code %d, got %d", exitUsage, code)
+	}
+	if code := run([]string{"-dsn", "", "migrate"}, stdout, stderr); code != exitUsage {
+		t.Errorf("expect exit code %d, got %d", exitUsage, code)
+	}
+}
+
+func TestRunNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomigrate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "V2_1__test_table1.sql"), nil, 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"-dir", dir, "new", "add users table"}, stdout, stderr); code != exitOK {
+		t.Error(stderr.String())
+		t.FailNow()
+	}
+	if code := run([]string{"-dir", dir, "new", "-bump", "minor", "-undo", "add orders table"}, stdout, stderr); code != exitOK {
+		t.Error(stderr.String())
+		t.FailNow()
+	}
+	for _, filename := range []string{"V3__add_users_table.sql", "V3_1__add_orders_table.sql", "U3_1__add_orders_table.sql"} {
+		if _, err = os.Stat(filepath.Join(dir, filename)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomigrate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	migrationPath := filepath.Join(dir, "V1__test_cli_table1.sql")
+	err = ioutil.WriteFile(migrationPath, []byte("create table if not exists test_cli_table1(id int)"), 0644)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	args := []string{"-dsn", mysqlTestSource, "-dir", dir, "-table", "test_cli_schema_history"}
+	db, err := sql.Open("mysql", mysqlTestSource)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer db.Close()
+	defer func() {
+		db.Exec("DROP TABLE `test_cli_schema_history`")
+		db.Exec("DROP TABLE `test_cli_table1`")
+	}()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run(append(args, "migrate"), stdout, stderr); code != exitOK {
+		t.Error(stderr.String())
+		t.FailNow()
+	}
+	if code := run(append(args, "validate"), stdout, stderr); code != exitOK {
+		t.Error(stderr.String())
+		t.FailNow()
+	}
+	if err = os.Remove(migrationPath); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if code := run(append(args, "validate"), stdout, stderr); code != exitMigrationMissing {
+		t.Errorf("expect exit code %d, got %d: %s", exitMigrationMissing, code, stderr.String())
+	}
+}
+
+func TestRunStrict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomigrate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "4_1__test_table1.sql"), nil, 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"-dsn", mysqlTestSource, "-dir", dir, "-strict", "validate"}, stdout, stderr); code != exitInvalidMigrations {
+		t.Errorf("expect exit code %d, got %d: %s", exitInvalidMigrations, code, stderr.String())
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("4_1__test_table1.sql")) {
+		t.Errorf("expect the ignored file to be reported, got %s", stderr.String())
+	}
+}
+
+func TestRunUsageHidesDSN(t *testing.T) {
+	os.Setenv("GOMIGRATE_DSN", "app:S3cretPw@tcp(db:3306)/prod")
+	defer os.Unsetenv("GOMIGRATE_DSN")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"bogus"}, stdout, stderr); code != exitUsage {
+		t.Errorf("expect exit code %d, got %d", exitUsage, code)
+	}
+	if bytes.Contains(stderr.Bytes(), []byte("S3cretPw")) {
+		t.Errorf("usage must not print the DSN: %s", stderr.String())
+	}
+}
@@ -3,26 +3,160 @@ package gomigrate
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultSchemaHistoryTableName       = "gomigrate_schema_history"
+	DefaultFlywaySchemaHistoryTableName = "flyway_schema_history"
+	DefaultLockTimeout                  = time.Minute
 )
 
 type MigrateStatus int
 
+// 状态值保持稳定, 新增状态只能追加
 const (
-	DefaultSchemaHistoryTableName = "gomigrate_schema_history"
-
-	StatusUnknown MigrateStatus = iota
-	StatusInstalled
-	StatusReadyToInstall
-	StatusMigrationMissing
-	StatusMigrationModified
-	StatusBrokenSchemaHistory
+	StatusUnknown             MigrateStatus = 1
+	StatusInstalled           MigrateStatus = 2
+	StatusReadyToInstall      MigrateStatus = 3
+	StatusMigrationMissing    MigrateStatus = 4
+	StatusMigrationModified   MigrateStatus = 5
+	StatusBrokenSchemaHistory MigrateStatus = 6
+	StatusOutdated            MigrateStatus = 7
+	StatusSuperseded          MigrateStatus = 8
+	StatusBaseline            MigrateStatus = 9
+	StatusFailed              MigrateStatus = 10
+	StatusAboveTarget         MigrateStatus = 11
+	// StatusUndone Flyway撤销(undo)的记录及其撤销记录
+	StatusUndone MigrateStatus = 12
+	// StatusDeleted Flyway repair标记删除的记录及其删除记录
+	StatusDeleted MigrateStatus = 13
+)
+
+var migrateStatusTexts = map[MigrateStatus]string{
+	StatusUnknown:             "UNKNOWN",
+	StatusInstalled:           "INSTALLED",
+	StatusReadyToInstall:      "READY TO INSTALL",
+	StatusMigrationMissing:    "MIGRATION MISSING",
+	StatusMigrationModified:   "MIGRATION MODIFIED",
+	StatusBrokenSchemaHistory: "SCHEMA BROKEN",
+	StatusOutdated:            "OUTDATED",
+	StatusSuperseded:          "SUPERSEDED",
+	StatusBaseline:            "BASELINE",
+	StatusFailed:              "FAILED",
+	StatusAboveTarget:         "ABOVE TARGET",
+	StatusUndone:              "UNDONE",
+	StatusDeleted:             "DELETED",
+}
+
+func (s MigrateStatus) String() string {
+	if text, ok := migrateStatusTexts[s]; ok {
+		return text
+	}
+	return migrateStatusTexts[StatusUnknown]
+}
+
+const (
+	MigrationTypeSQL            = "SQL"
+	MigrationTypeRepeatable     = "SQL_REPEATABLE"
+	MigrationTypeFunc           = "GO"
+	MigrationTypeRepeatableFunc = "GO_REPEATABLE"
+	// MigrationTypeJDBC Flyway中Java实现的Migration的类型, Flyway风格的Schema History中用于Go函数Migration
+	MigrationTypeJDBC     = "JDBC"
+	MigrationTypeBaseline = "BASELINE"
+	// 以下类型只会由Flyway写入: 创建schema的标记, 撤销的记录和repair标记删除的记录
+	MigrationTypeSchema   = "SCHEMA"
+	MigrationTypeUndoSQL  = "UNDO_SQL"
+	MigrationTypeUndoJDBC = "UNDO_JDBC"
+	MigrationTypeDelete   = "DELETE"
+
+	baselineName       = "<< Baseline >>"
+	flywayBaselineName = "<< Flyway Baseline >>"
 )
 
 var (
-	ErrInitializeFail          = errors.New("failed to initialize")
-	ErrBrokenSchemaHistory     = errors.New("broken schema history detected")
-	ErrDuplicatedMigrationName = errors.New("duplicated migration name detected")
-	ErrInvalidMigrations       = errors.New("invalid migrations")
-	ErrMigrationMissing        = fmt.Errorf("%w(missing)", ErrInvalidMigrations)
-	ErrMigrationModified       = fmt.Errorf("%w(modified)", ErrInvalidMigrations)
+	ErrInitializeFail             = errors.New("failed to initialize")
+	ErrBrokenSchemaHistory        = errors.New("broken schema history detected")
+	ErrDuplicatedMigrationName    = errors.New("duplicated migration name detected")
+	ErrDuplicatedMigrationVersion = errors.New("duplicated migration version detected")
+	ErrInvalidMigrations          = errors.New("invalid migrations")
+	ErrMigrationMissing           = fmt.Errorf("%w(missing)", ErrInvalidMigrations)
+	ErrMigrationModified          = fmt.Errorf("%w(modified)", ErrInvalidMigrations)
+	ErrMigrationNotFound          = errors.New("migration not found")
+	ErrDownMigrationMissing       = errors.New("down migration missing")
+	ErrSchemaHistoryNotEmpty      = errors.New("schema history is not empty")
+	ErrUnknownOutputFormat        = errors.New("unknown output format")
+	ErrUnknownVersionBump         = errors.New("unknown version bump")
+	ErrLockTimeout                = errors.New("timeout waiting for migration lock")
+	ErrMigrationFailed            = errors.New("migration failed")
+	ErrNoFailedMigration          = errors.New("no failed migration")
+	ErrFailedStatementUnknown     = errors.New("failed statement unknown")
+	ErrFuncMigrationNotInSQL      = errors.New("go function migration cannot be written as sql")
+)
+
+// MigrationError 是执行Migration失败时返回的错误, errors.Is(err, ErrMigrationFailed)为true
+type MigrationError struct {
+	Name string
+	// Statement 为失败语句的序号, 从1开始
+	Statement int
+	// SQL 为失败的语句, 不包含注释
+	SQL string
+	// Line 为失败语句在Migration中的起始行号, 从1开始
+	Line int
+	Err  error
+}
+
+func (e *MigrationError) Error() string {
+	if e.SQL == "" {
+		return fmt.Sprintf("%s: %s: %s", ErrMigrationFailed, e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s: %s, statement %d at line %d: %s\n%s", ErrMigrationFailed, e.Name, e.Statement, e.Line, e.Err, e.SQL)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *MigrationError) Is(target error) bool {
+	return target == ErrMigrationFailed
+}
+
+type FlywayProblemKind string
+
+const (
+	FlywayDuplicateVersion FlywayProblemKind = "duplicate version"
+	FlywayDuplicateName    FlywayProblemKind = "duplicate name"
+	FlywayInvalidName      FlywayProblemKind = "invalid name"
+	FlywayIgnoredFile      FlywayProblemKind = "ignored file"
 )
+
+// FlywayProblem 是解析Flyway风格Migration目录时发现的问题
+type FlywayProblem struct {
+	Kind FlywayProblemKind
+	// Paths 为相关文件在fsys中的路径, 重复时有多个
+	Paths  []string
+	Detail string
+}
+
+func (p FlywayProblem) String() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Kind, p.Detail, strings.Join(p.Paths, ", "))
+}
+
+// FlywayParseError 是严格模式下解析Flyway风格Migration目录发现问题时返回的错误,
+// errors.Is(err, ErrInvalidMigrations)为true
+type FlywayParseError struct {
+	Problems []FlywayProblem
+}
+
+func (e *FlywayParseError) Error() string {
+	lines := []string{fmt.Sprintf("%s: %d problems in flyway migrations", ErrInvalidMigrations, len(e.Problems))}
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *FlywayParseError) Is(target error) bool {
+	return target == ErrInvalidMigrations
+}
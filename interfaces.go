@@ -1,20 +1,80 @@
 package gomigrate
 
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
 type MigrationExecutor interface {
 	GetSchemaHistoryTableName() string
 	SetSchemaHistoryTableName(tableName string) error
 	SetMigrations(migrations []Migration)
+	SetOutput(w io.Writer)
 	InitSchemaHistoryTable() error
+	InitSchemaHistoryTableContext(ctx context.Context) error
+	CheckMigrations() error
+	CheckMigrationsContext(ctx context.Context) error
+	GetMigrationInfos() ([]MigrationInfo, error)
+	GetMigrationInfosContext(ctx context.Context) ([]MigrationInfo, error)
 	ShowMigrations() error
+	ShowMigrationsContext(ctx context.Context) error
 	InstallMigrations() error
+	InstallMigrationsContext(ctx context.Context) error
+	InstallMigrationsTo(target string) error
+	InstallMigrationsToContext(ctx context.Context, target string) error
+	ResumeFailedMigration() error
+	ResumeFailedMigrationContext(ctx context.Context) error
+	MarkFailedMigrationFixed() error
+	MarkFailedMigrationFixedContext(ctx context.Context) error
+	Rollback(n int) error
+	RollbackContext(ctx context.Context, n int) error
+	RollbackTo(target string) error
+	RollbackToContext(ctx context.Context, target string) error
+	RollbackToRank(rank int) error
+	RollbackToRankContext(ctx context.Context, rank int) error
+	Baseline(version string, description string) error
+	BaselineContext(ctx context.Context, version string, description string) error
+	Repair(options RepairOptions) (*RepairReport, error)
+	RepairContext(ctx context.Context, options RepairOptions) (*RepairReport, error)
+	Plan() (*MigrationPlan, error)
+	PlanContext(ctx context.Context) (*MigrationPlan, error)
+	Close() error
+}
+
+// dbConn 由*sql.DB和*sql.Conn实现
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
 
 type BaseExecutor struct {
-	tableName string
+	tableName     string
+	flywayHistory bool
+	output        io.Writer
+	renderOptions RenderOptions
+	// withoutContent 为true时Schema History只保存checksum, 不保存完整内容
+	withoutContent bool
+	installedBy    string
+	appVersion     string
+	lockTimeout    time.Duration
+	// target 不为空时只安装到该Migration为止
+	target     string
+	outOfOrder bool
+	callbacks  map[CallbackEvent][]Callback
+	logger     Logger
 }
 
 func (b *BaseExecutor) GetSchemaHistoryTableName() string {
 	if b.tableName == "" {
+		if b.flywayHistory {
+			return DefaultFlywaySchemaHistoryTableName
+		}
 		return DefaultSchemaHistoryTableName
 	}
 	return b.tableName
@@ -24,3 +84,60 @@ func (b *BaseExecutor) SetSchemaHistoryTableName(tableName string) error {
 	b.tableName = tableName
 	return nil
 }
+
+// SetOutput 设置ShowMigrations和Repair的输出位置, 默认为os.Stdout
+func (b *BaseExecutor) SetOutput(w io.Writer) {
+	b.output = w
+}
+
+func (b *BaseExecutor) getOutput() io.Writer {
+	if b.output == nil {
+		return os.Stdout
+	}
+	return b.output
+}
+
+func (b *BaseExecutor) getLogger() Logger {
+	if b.logger == nil {
+		return nopLogger{}
+	}
+	return b.logger
+}
+
+func (b *BaseExecutor) getLockTimeout() time.Duration {
+	if b.lockTimeout <= 0 {
+		return DefaultLockTimeout
+	}
+	return b.lockTimeout
+}
+
+// installedByArg 未指定时为NULL, 由数据库取当前用户
+func (b *BaseExecutor) installedByArg() sql.NullString {
+	return sql.NullString{String: b.installedBy, Valid: b.installedBy != ""}
+}
+
+func installedHost() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// buildMigrateInfos 根据是否允许乱序选择Schema History与Migration的对应方式, Flyway格式的Schema History允许rank有空缺
+func (b *BaseExecutor) buildMigrateInfos(schemaHistories []SchemaHistory, migrations []Migration) []MigrationInfo {
+	return matchMigrateInfos(schemaHistories, migrations, b.migrationChecksum, b.outOfOrder, b.flywayHistory)
+}
+
+// migrationChecksum 返回与Schema History中checksum列格式一致的校验值
+func (b *BaseExecutor) migrationChecksum(migration *Migration) string {
+	if b.flywayHistory {
+		return strconv.FormatInt(int64(migration.GetFlywayChecksum()), 10)
+	}
+	return migration.GetContentHash()
+}
+
+// schemaHistoryContent 返回写入content列的值, 不保存完整内容时为NULL
+func (b *BaseExecutor) schemaHistoryContent(migration *Migration) sql.NullString {
+	return sql.NullString{String: migration.Content, Valid: !b.withoutContent}
+}
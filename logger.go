@@ -0,0 +1,64 @@
+package gomigrate
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger 与*slog.Logger的方法签名一致, 可以直接使用slog.Default(), args为交替的键和值
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+type stdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+// NewStdLogger 将日志以 "LEVEL msg key=value" 的格式写入标准库的*log.Logger, debug为false时忽略Debug日志
+func NewStdLogger(logger *log.Logger, debug bool) Logger {
+	return &stdLogger{logger: logger, debug: debug}
+}
+
+func (l *stdLogger) Debug(msg string, args ...interface{}) {
+	if l.debug {
+		l.print("DEBUG", msg, args)
+	}
+}
+
+func (l *stdLogger) Info(msg string, args ...interface{}) {
+	l.print("INFO", msg, args)
+}
+
+func (l *stdLogger) Warn(msg string, args ...interface{}) {
+	l.print("WARN", msg, args)
+}
+
+func (l *stdLogger) Error(msg string, args ...interface{}) {
+	l.print("ERROR", msg, args)
+}
+
+func (l *stdLogger) print(level string, msg string, args []interface{}) {
+	var builder strings.Builder
+	builder.WriteString(level + " " + msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&builder, " %v=%q", args[i], fmt.Sprint(args[i+1]))
+		} else {
+			// 与slog一致, 缺少键的值使用!BADKEY
+			fmt.Fprintf(&builder, " !BADKEY=%q", fmt.Sprint(args[i]))
+		}
+	}
+	l.logger.Print(builder.String())
+}
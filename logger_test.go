@@ -0,0 +1,20 @@
+package gomigrate
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := NewStdLogger(log.New(buffer, "", 0), false)
+	logger.Debug("ignored")
+	logger.Info("installed migration", "migration", "V1__init.sql", "rank", 1)
+	logger.Error("migration failed", "error")
+	expected := "INFO installed migration migration=\"V1__init.sql\" rank=\"1\"\n" +
+		"ERROR migration failed !BADKEY=\"error\"\n"
+	if buffer.String() != expected {
+		t.Errorf("expect %q, got %q", expected, buffer.String())
+	}
+}
@@ -0,0 +1,328 @@
+package gomigrate
+
+import (
+	"fmt"
+	"time"
+)
+
+type MigrationInfo struct {
+	// Rank 为0表示没有对应的Schema History
+	Rank          int
+	SchemaName    string
+	MigrationName string
+	// InstalledTime 为零值表示没有对应的Schema History
+	InstalledTime time.Time
+	Status        MigrateStatus
+	SchemaHistory *SchemaHistory
+	Migration     *Migration
+}
+
+type migrateInfoBuilder func(schemaHistories []SchemaHistory, migrations []Migration) []MigrationInfo
+
+// buildMigrateInfos 将Schema History与Migration一一对应:
+// 版本化的Migration按顺序对应, 可重复执行的Migration按名称对应, 同名的只有最新一条有效
+func buildMigrateInfos(schemaHistories []SchemaHistory, migrations []Migration, checksum func(migration *Migration) string) []MigrationInfo {
+	return matchMigrateInfos(schemaHistories, migrations, checksum, false, false)
+}
+
+// buildOutOfOrderMigrateInfos 与buildMigrateInfos相同, 但版本化的Migration按名称或版本号对应,
+// 低于已安装版本的新Migration为READY TO INSTALL, 而不是使之后的记录都变为MIGRATION MODIFIED
+func buildOutOfOrderMigrateInfos(schemaHistories []SchemaHistory, migrations []Migration, checksum func(migration *Migration) string) []MigrationInfo {
+	return matchMigrateInfos(schemaHistories, migrations, checksum, true, false)
+}
+
+// matchMigrateInfos allowRankGaps为true时rank的空缺不视为Schema History被破坏,
+// Flyway的repair会删除失败的记录而不重新编号, 记录只按rank的顺序对应
+func matchMigrateInfos(schemaHistories []SchemaHistory, migrations []Migration, checksum func(migration *Migration) string, outOfOrder bool, allowRankGaps bool) []MigrationInfo {
+	versionedMigrations := make([]*Migration, 0, len(migrations))
+	repeatableMigrations := make(map[string]*Migration)
+	for i := range migrations {
+		if migrations[i].Repeatable {
+			repeatableMigrations[migrations[i].Name] = &migrations[i]
+		} else {
+			versionedMigrations = append(versionedMigrations, &migrations[i])
+		}
+	}
+
+	retired := retiredSchemaHistories(schemaHistories)
+	latestRepeatableRanks := make(map[string]int)
+	for _, schemaHistory := range schemaHistories {
+		if _, ok := retired[schemaHistory.Rank]; !ok && schemaHistory.Repeatable {
+			latestRepeatableRanks[schemaHistory.Name] = schemaHistory.Rank
+		}
+	}
+
+	migrateInfos := make([]MigrationInfo, 0, len(schemaHistories)+len(migrations))
+	installedRepeatables := make(map[string]bool)
+	matched := make(map[*Migration]bool)
+	// nextMigration 按顺序取下一个未对应的版本化Migration
+	nextMigration := func() *Migration {
+		for _, migration := range versionedMigrations {
+			if !matched[migration] {
+				matched[migration] = true
+				return migration
+			}
+		}
+		return nil
+	}
+	expectedRank := 1
+	for i := range schemaHistories {
+		schemaHistory := &schemaHistories[i]
+		// rank不连续说明Schema History被破坏
+		for ; !allowRankGaps && expectedRank < schemaHistory.Rank; expectedRank++ {
+			migrateInfo := MigrationInfo{Status: StatusBrokenSchemaHistory}
+			if !outOfOrder {
+				migrateInfo.Migration = nextMigration()
+			}
+			migrateInfos = append(migrateInfos, migrateInfo)
+		}
+		expectedRank = schemaHistory.Rank + 1
+
+		if schemaHistory.isSchemaMarker() {
+			migrateInfos = append(migrateInfos, MigrationInfo{SchemaHistory: schemaHistory, Status: StatusBaseline})
+			continue
+		}
+		if status, ok := retired[schemaHistory.Rank]; ok {
+			migrateInfos = append(migrateInfos, MigrationInfo{SchemaHistory: schemaHistory, Status: status})
+			continue
+		}
+		if schemaHistory.isBaseline() {
+			// 基线之前的Migration视为已安装
+			for _, migration := range versionedMigrations[:baselineCoveredCount(versionedMigrations, schemaHistory.Version)] {
+				if !matched[migration] {
+					matched[migration] = true
+					migrateInfos = append(migrateInfos, MigrationInfo{Migration: migration, Status: StatusBaseline})
+				}
+			}
+			migrateInfos = append(migrateInfos, MigrationInfo{SchemaHistory: schemaHistory, Status: StatusBaseline})
+			continue
+		}
+
+		migrateInfo := MigrationInfo{SchemaHistory: schemaHistory}
+		if schemaHistory.Repeatable {
+			migrateInfo.Migration = repeatableMigrations[schemaHistory.Name]
+			installedRepeatables[schemaHistory.Name] = true
+		} else if outOfOrder {
+			migrateInfo.Migration = findInstalledMigration(versionedMigrations, matched, schemaHistory)
+			if migrateInfo.Migration != nil {
+				matched[migrateInfo.Migration] = true
+			}
+		} else {
+			migrateInfo.Migration = nextMigration()
+		}
+		if schemaHistory.Repeatable && latestRepeatableRanks[schemaHistory.Name] != schemaHistory.Rank {
+			migrateInfo.Status = StatusSuperseded
+		} else if !schemaHistory.Success {
+			// 失败的Migration可能已被修改以修复失败的语句, 不比较内容
+			migrateInfo.Status = StatusFailed
+		} else if migrateInfo.Migration == nil {
+			migrateInfo.Status = StatusMigrationMissing
+		} else if !isSchemaHistoryMatched(schemaHistory, migrateInfo.Migration, checksum) {
+			if migrateInfo.Migration.Repeatable {
+				migrateInfo.Status = StatusOutdated
+			} else {
+				migrateInfo.Status = StatusMigrationModified
+			}
+		} else {
+			migrateInfo.Status = StatusInstalled
+		}
+		migrateInfos = append(migrateInfos, migrateInfo)
+	}
+
+	for _, migration := range versionedMigrations {
+		if !matched[migration] {
+			migrateInfos = append(migrateInfos, MigrationInfo{Migration: migration, Status: StatusReadyToInstall})
+		}
+	}
+	for i := range migrations {
+		if migrations[i].Repeatable && !installedRepeatables[migrations[i].Name] {
+			migrateInfos = append(migrateInfos, MigrationInfo{Migration: &migrations[i], Status: StatusReadyToInstall})
+		}
+	}
+
+	for i := range migrateInfos {
+		if schemaHistory := migrateInfos[i].SchemaHistory; schemaHistory != nil {
+			migrateInfos[i].Rank = schemaHistory.Rank
+			migrateInfos[i].SchemaName = schemaHistory.Name
+			migrateInfos[i].InstalledTime = schemaHistory.InstalledTime
+		}
+		if migrateInfos[i].Migration != nil {
+			migrateInfos[i].MigrationName = migrateInfos[i].Migration.Name
+		}
+	}
+	return migrateInfos
+}
+
+// retiredSchemaHistories 返回被Flyway撤销或删除而失效的记录的状态(按rank索引), 包括撤销和删除记录本身.
+// 撤销或删除记录使之前最近一条同一版本的成功记录失效, 没有版本号时按名称对应可重复执行的记录
+func retiredSchemaHistories(schemaHistories []SchemaHistory) map[int]MigrateStatus {
+	retired := make(map[int]MigrateStatus)
+	for i := range schemaHistories {
+		retiring := &schemaHistories[i]
+		status := retiring.retiringStatus()
+		if status == 0 {
+			continue
+		}
+		retired[retiring.Rank] = status
+		for j := i - 1; j >= 0; j-- {
+			previous := &schemaHistories[j]
+			if _, ok := retired[previous.Rank]; ok || !previous.Success || previous.isBaseline() || previous.isSchemaMarker() {
+				continue
+			}
+			if (retiring.Version != "" && previous.Version == retiring.Version) ||
+				(retiring.Version == "" && previous.Repeatable && previous.Name == retiring.Name) {
+				retired[previous.Rank] = status
+				break
+			}
+		}
+	}
+	return retired
+}
+
+// findInstalledMigration 查找与记录对应的未对应的Migration, 先按名称, 再按版本号
+func findInstalledMigration(versionedMigrations []*Migration, matched map[*Migration]bool, schemaHistory *SchemaHistory) *Migration {
+	for _, migration := range versionedMigrations {
+		if !matched[migration] && migration.Name == schemaHistory.Name {
+			return migration
+		}
+	}
+	if schemaHistory.Version == "" {
+		return nil
+	}
+	for _, migration := range versionedMigrations {
+		if !matched[migration] && matchMigrationVersion(migration, schemaHistory.Version) {
+			return migration
+		}
+	}
+	return nil
+}
+
+// baselineCoveredCount 返回被基线覆盖的Migration数量, 基线版本号也可以是Migration名称
+func baselineCoveredCount(versionedMigrations []*Migration, baselineVersion string) int {
+	for i, migration := range versionedMigrations {
+		if migration.Name == baselineVersion {
+			return i + 1
+		}
+	}
+	version, err := ParseMigrationVersion(baselineVersion)
+	if err != nil {
+		return 0
+	}
+	covered := 0
+	for _, migration := range versionedMigrations {
+		migrationVersion, err := ParseMigrationVersion(migration.Version)
+		if migration.Version == "" || err != nil || migrationVersion.Compare(version) > 0 {
+			break
+		}
+		covered++
+	}
+	return covered
+}
+
+// isSchemaHistoryMatched 有checksum时比较checksum, 否则比较完整内容(旧版本写入的记录)
+func isSchemaHistoryMatched(schemaHistory *SchemaHistory, migration *Migration, checksum func(migration *Migration) string) bool {
+	if schemaHistory.Name != migration.Name {
+		return false
+	}
+	if schemaHistory.Checksum != "" && checksum != nil {
+		return schemaHistory.Checksum == checksum(migration)
+	}
+	return schemaHistory.Content == migration.Content
+}
+
+// checkMigrateInfos 按 SCHEMA BROKEN, MIGRATION MISSING, MIGRATION MODIFIED, FAILED 的优先级返回错误
+func checkMigrateInfos(migrateInfos []MigrationInfo) error {
+	for _, status := range []MigrateStatus{StatusBrokenSchemaHistory, StatusMigrationMissing, StatusMigrationModified, StatusFailed} {
+		for _, migrateInfo := range migrateInfos {
+			if migrateInfo.Status != status {
+				continue
+			}
+			switch status {
+			case StatusBrokenSchemaHistory:
+				return ErrBrokenSchemaHistory
+			case StatusMigrationMissing:
+				return fmt.Errorf("%w: %s", ErrMigrationMissing, migrateInfo.SchemaHistory.Name)
+			case StatusFailed:
+				return fmt.Errorf("%w: %s, resume it or mark it fixed", ErrMigrationFailed, migrateInfo.SchemaHistory.Name)
+			default:
+				return fmt.Errorf("%w: %s", ErrMigrationModified, migrateInfo.SchemaHistory.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyTarget 将target之后待安装的版本化Migration标记为ABOVE TARGET, target为Migration名称或版本号, 为空时不限制
+func applyTarget(migrateInfos []MigrationInfo, migrations []Migration, target string) error {
+	if target == "" {
+		return nil
+	}
+	index := findMigration(migrations, target)
+	if index < 0 || migrations[index].Repeatable {
+		return fmt.Errorf("%w: target %s", ErrMigrationNotFound, target)
+	}
+
+	// 按Migration的顺序而不是记录的顺序比较, 乱序模式下低于target的新Migration仍然可以安装
+	aboveTarget := make(map[*Migration]bool)
+	for i := index + 1; i < len(migrations); i++ {
+		aboveTarget[&migrations[i]] = true
+	}
+	for i := range migrateInfos {
+		migration := migrateInfos[i].Migration
+		if migration != nil && !migration.Repeatable && aboveTarget[migration] && migrateInfos[i].Status == StatusReadyToInstall {
+			migrateInfos[i].Status = StatusAboveTarget
+		}
+	}
+	return nil
+}
+
+type pendingMigration struct {
+	Migration *Migration
+	Rank      int
+	// Reinstall 为true时重新执行可重复执行的Migration并更新原有记录
+	Reinstall bool
+}
+
+// pendingMigrations 返回待安装的Migration并分配rank, 版本化的在前, 可重复执行的在后.
+// appendReinstalls为true时重新执行的可重复执行的Migration写入新的记录而不是更新原有记录, 与Flyway一致
+func pendingMigrations(migrateInfos []MigrationInfo, appendReinstalls bool) []pendingMigration {
+	nextRank := 1
+	for _, migrateInfo := range migrateInfos {
+		if migrateInfo.SchemaHistory != nil && migrateInfo.SchemaHistory.Rank >= nextRank {
+			nextRank = migrateInfo.SchemaHistory.Rank + 1
+		}
+	}
+
+	pendings := make([]pendingMigration, 0)
+	for _, migrateInfo := range migrateInfos {
+		if migrateInfo.Status == StatusReadyToInstall && !migrateInfo.Migration.Repeatable {
+			pendings = append(pendings, pendingMigration{Migration: migrateInfo.Migration, Rank: nextRank})
+			nextRank++
+		}
+	}
+	for _, migrateInfo := range migrateInfos {
+		if migrateInfo.Migration == nil || !migrateInfo.Migration.Repeatable {
+			continue
+		}
+		if migrateInfo.Status == StatusReadyToInstall || (migrateInfo.Status == StatusOutdated && appendReinstalls) {
+			pendings = append(pendings, pendingMigration{Migration: migrateInfo.Migration, Rank: nextRank})
+			nextRank++
+		} else if migrateInfo.Status == StatusOutdated {
+			pendings = append(pendings, pendingMigration{Migration: migrateInfo.Migration, Rank: migrateInfo.SchemaHistory.Rank, Reinstall: true})
+		}
+	}
+	return pendings
+}
+
+// installedMigrateInfos 返回已安装的版本化Migration, 按rank排序
+func installedMigrateInfos(migrateInfos []MigrationInfo) []*MigrationInfo {
+	installedInfos := make([]*MigrationInfo, 0)
+	for i := range migrateInfos {
+		schemaHistory := migrateInfos[i].SchemaHistory
+		if schemaHistory != nil && !schemaHistory.Repeatable && !schemaHistory.isBaseline() && !schemaHistory.isSchemaMarker() &&
+			migrateInfos[i].Status != StatusUndone && migrateInfos[i].Status != StatusDeleted {
+			installedInfos = append(installedInfos, &migrateInfos[i])
+		}
+	}
+	return installedInfos
+}
@@ -0,0 +1,209 @@
+package gomigrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildMigrateInfos(t *testing.T) {
+	migrations := []Migration{
+		{Name: "test_table1", Content: "content1"},
+		{Name: "test_table2", Content: "content2"},
+		{Name: "test_view1", Content: "view1", Repeatable: true},
+		{Name: "test_view2", Content: "view2 modified", Repeatable: true},
+		{Name: "test_view3", Content: "view3", Repeatable: true},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "test_table1", Content: "content1"}, Rank: 1, Type: MigrationTypeSQL, Success: true},
+		{Migration: Migration{Name: "test_view1", Content: "view1", Repeatable: true}, Rank: 2, Type: MigrationTypeRepeatable, Success: true},
+		{Migration: Migration{Name: "test_view2", Content: "view2", Repeatable: true}, Rank: 3, Type: MigrationTypeRepeatable, Success: true},
+	}
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+	statuses := []MigrateStatus{StatusInstalled, StatusInstalled, StatusOutdated, StatusReadyToInstall, StatusReadyToInstall}
+	if len(migrateInfos) != len(statuses) {
+		t.FailNow()
+	}
+	for i, status := range statuses {
+		if migrateInfos[i].Status != status {
+			t.Errorf("migrate info %d: expect status %d, got %d", i, status, migrateInfos[i].Status)
+		}
+	}
+	if err := checkMigrateInfos(migrateInfos); err != nil {
+		t.Error(err)
+	}
+
+	// 版本化的Migration先于可重复执行的Migration安装
+	pendings := pendingMigrations(migrateInfos, false)
+	names := []string{"test_table2", "test_view2", "test_view3"}
+	ranks := []int{4, 3, 5}
+	if len(pendings) != len(names) {
+		t.FailNow()
+	}
+	for i, name := range names {
+		if pendings[i].Migration.Name != name || pendings[i].Rank != ranks[i] {
+			t.Errorf("expect %s at rank %d, got %s at rank %d", name, ranks[i], pendings[i].Migration.Name, pendings[i].Rank)
+		}
+	}
+	if pendings[0].Reinstall || !pendings[1].Reinstall {
+		t.FailNow()
+	}
+}
+
+func TestBuildMigrateInfosWithBrokenSchemaHistory(t *testing.T) {
+	migrations := []Migration{
+		{Name: "test_table1", Content: "content1"},
+		{Name: "test_table2", Content: "content2"},
+		{Name: "test_table3", Content: "content3"},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "test_table1", Content: "content1"}, Rank: 1, Type: MigrationTypeSQL, Success: true},
+		{Migration: Migration{Name: "test_table3", Content: "content3"}, Rank: 3, Type: MigrationTypeSQL, Success: true},
+	}
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+	if migrateInfos[1].Status != StatusBrokenSchemaHistory || migrateInfos[1].Migration.Name != "test_table2" {
+		t.FailNow()
+	}
+	if !errors.Is(checkMigrateInfos(migrateInfos), ErrBrokenSchemaHistory) {
+		t.FailNow()
+	}
+
+	migrateInfos = buildMigrateInfos(schemaHistories[:1], migrations[1:], nil)
+	if !errors.Is(checkMigrateInfos(migrateInfos), ErrMigrationModified) {
+		t.FailNow()
+	}
+	migrateInfos = buildMigrateInfos(schemaHistories, migrations[:1], nil)
+	if !errors.Is(checkMigrateInfos(migrateInfos), ErrBrokenSchemaHistory) {
+		t.FailNow()
+	}
+}
+
+func TestBuildMigrateInfosWithBaseline(t *testing.T) {
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: "content1"},
+		{Name: "V1_1__test_table2.sql", Version: "1.1", Content: "content2"},
+		{Name: "V2__test_table3.sql", Version: "2", Content: "content3"},
+		{Name: "V3__test_table4.sql", Version: "3", Content: "content4"},
+	}
+	schemaHistories := []SchemaHistory{
+		*newBaselineSchemaHistory("V1_1", "", false),
+		{Migration: Migration{Name: "V2__test_table3.sql", Content: "content3"}, Rank: 2, Type: MigrationTypeSQL, Success: true},
+	}
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+	statuses := []MigrateStatus{StatusBaseline, StatusBaseline, StatusBaseline, StatusInstalled, StatusReadyToInstall}
+	if len(migrateInfos) != len(statuses) {
+		t.FailNow()
+	}
+	for i, status := range statuses {
+		if migrateInfos[i].Status != status {
+			t.Errorf("migrate info %d: expect status %d, got %d", i, status, migrateInfos[i].Status)
+		}
+	}
+	if err := checkMigrateInfos(migrateInfos); err != nil {
+		t.Error(err)
+	}
+	if installedInfos := installedMigrateInfos(migrateInfos); len(installedInfos) != 1 {
+		t.FailNow()
+	}
+}
+
+func TestPlanRepair(t *testing.T) {
+	migrations := []Migration{
+		{Name: "test_table1", Content: "content1"},
+		{Name: "test_table2", Content: "content2 modified"},
+		{Name: "test_table3", Content: "content3 modified"},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "test_table1", Content: "content1"}, Rank: 1, Success: true},
+		{Migration: Migration{Name: "test_table2", Content: "content2"}, Rank: 3, Success: true},
+		{Migration: Migration{Name: "test_table3", Content: "content3"}, Rank: 4, Success: true},
+		{Migration: Migration{Name: "test_table4", Content: "content4"}, Rank: 5, Success: false},
+	}
+	report := planRepair(schemaHistories, migrations, func(schemaHistories []SchemaHistory, migrations []Migration) []MigrationInfo {
+		return buildMigrateInfos(schemaHistories, migrations, nil)
+	}, RepairOptions{DryRun: true, AcceptModified: []string{"test_table2"}}, false)
+	if len(report.Removed) != 1 || report.Removed[0].Rank != 5 {
+		t.FailNow()
+	}
+	if len(report.Renumbered) != 2 || report.Renumbered[0].OldRank != 3 || report.Renumbered[0].NewRank != 2 {
+		t.FailNow()
+	}
+	if len(report.Realigned) != 1 || report.Realigned[0].Rank != 2 || report.Realigned[0].Migration.Content != "content2 modified" {
+		t.FailNow()
+	}
+	if len(report.Modified) != 1 || report.Modified[0] != "test_table3" {
+		t.FailNow()
+	}
+}
+
+func TestApplyTarget(t *testing.T) {
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: "content1"},
+		{Name: "V3_1__test_table2.sql", Version: "3.1", Content: "content2"},
+		{Name: "V4__test_table3.sql", Version: "4", Content: "content3"},
+		{Name: "R__test_view1.sql", Content: "view1", Repeatable: true},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "V1__test_table1.sql", Content: "content1"}, Rank: 1, Type: MigrationTypeSQL, Success: true},
+	}
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+	if err := applyTarget(migrateInfos, migrations, "V3_1"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	statuses := []MigrateStatus{StatusInstalled, StatusReadyToInstall, StatusAboveTarget, StatusReadyToInstall}
+	for i, status := range statuses {
+		if migrateInfos[i].Status != status {
+			t.Errorf("migrate info %d: expect status %s, got %s", i, status, migrateInfos[i].Status)
+		}
+	}
+	pendings := pendingMigrations(migrateInfos, false)
+	if len(pendings) != 2 || pendings[0].Migration.Name != "V3_1__test_table2.sql" || pendings[1].Migration.Name != "R__test_view1.sql" {
+		t.FailNow()
+	}
+
+	if err := applyTarget(migrateInfos, migrations, "V5"); !errors.Is(err, ErrMigrationNotFound) {
+		t.FailNow()
+	}
+}
+
+func TestBuildOutOfOrderMigrateInfos(t *testing.T) {
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: "content1"},
+		{Name: "V1_1__hotfix.sql", Version: "1.1", Content: "hotfix"},
+		{Name: "V2__test_table2.sql", Version: "2", Content: "content2"},
+		{Name: "V3__test_table3.sql", Version: "3", Content: "content3"},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "V1__test_table1.sql", Version: "1", Content: "content1"}, Rank: 1, Type: MigrationTypeSQL, Success: true},
+		{Migration: Migration{Name: "V2__test_table2.sql", Version: "2", Content: "content2"}, Rank: 2, Type: MigrationTypeSQL, Success: true},
+	}
+
+	// 按顺序对应时插入的Migration使之后的记录都变为MIGRATION MODIFIED
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+	if migrateInfos[1].Status != StatusMigrationModified {
+		t.FailNow()
+	}
+
+	migrateInfos = buildOutOfOrderMigrateInfos(schemaHistories, migrations, nil)
+	statuses := []MigrateStatus{StatusInstalled, StatusInstalled, StatusReadyToInstall, StatusReadyToInstall}
+	names := []string{"V1__test_table1.sql", "V2__test_table2.sql", "V1_1__hotfix.sql", "V3__test_table3.sql"}
+	if len(migrateInfos) != len(statuses) {
+		t.FailNow()
+	}
+	for i, status := range statuses {
+		if migrateInfos[i].Status != status || migrateInfos[i].MigrationName != names[i] {
+			t.Errorf("migrate info %d: expect %s %s, got %s %s", i, names[i], status, migrateInfos[i].MigrationName, migrateInfos[i].Status)
+		}
+	}
+	pendings := pendingMigrations(migrateInfos, false)
+	if len(pendings) != 2 || pendings[0].Migration.Name != "V1_1__hotfix.sql" || pendings[0].Rank != 3 {
+		t.FailNow()
+	}
+
+	// 重命名过的Migration按版本号对应
+	migrations[2].Name = "V2__test_table2_renamed.sql"
+	migrateInfos = buildOutOfOrderMigrateInfos(schemaHistories, migrations, nil)
+	if migrateInfos[1].Migration != &migrations[2] || migrateInfos[1].Status != StatusMigrationModified {
+		t.FailNow()
+	}
+}
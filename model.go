@@ -1,8 +1,11 @@
 package gomigrate
 
 import (
+	"context"
 	"crypto/sha1"
+	"database/sql"
 	"encoding/hex"
+	"hash/crc32"
 	"strconv"
 	"strings"
 	"time"
@@ -10,13 +13,91 @@ import (
 
 type SchemaHistory struct {
 	Migration
-	Rank          int
+	Rank        int
+	Type        string
+	Description string
+	Checksum    string
+	// InstalledBy 为执行Migration的数据库用户, 可以通过WithInstalledBy指定
+	InstalledBy string
+	// InstalledHost 为执行Migration的机器名, Flyway格式的表没有这一列
+	InstalledHost string
+	// AppVersion 通过WithAppVersion指定, Flyway格式的表没有这一列
+	AppVersion string
+	// InstalledTime 为UTC时间
 	InstalledTime time.Time
+	ExecutionTime time.Duration
+	Success       bool
+	// FailedStatement 为失败语句的序号, 从1开始, 成功时为0. Flyway格式的表没有这一列
+	FailedStatement int
 }
 
+func newBaselineSchemaHistory(version string, description string, flyway bool) *SchemaHistory {
+	// 统一为Flyway的版本号格式, 如V3_1转为3.1
+	if migrationVersion, err := ParseMigrationVersion(strings.TrimLeft(version, "vV")); err == nil {
+		version = migrationVersion.String()
+	}
+	name := baselineName
+	if flyway {
+		name = flywayBaselineName
+	}
+	if description == "" {
+		description = name
+	}
+	return &SchemaHistory{
+		Migration:     Migration{Name: name, Version: version},
+		Rank:          1,
+		Type:          MigrationTypeBaseline,
+		Description:   description,
+		InstalledTime: time.Now().UTC(),
+		Success:       true,
+	}
+}
+
+func (s *SchemaHistory) getDescription() string {
+	if s.Description != "" {
+		return s.Description
+	}
+	return s.GetDescription()
+}
+
+func (s *SchemaHistory) isBaseline() bool {
+	return s.Type == MigrationTypeBaseline
+}
+
+// isSchemaMarker Flyway创建schema时写入的标记, 没有对应的Migration
+func (s *SchemaHistory) isSchemaMarker() bool {
+	return s.Type == MigrationTypeSchema
+}
+
+// retiringStatus Flyway的撤销或删除记录使之前同一Migration的记录失效, 返回失效后的状态, 其他记录返回0
+func (s *SchemaHistory) retiringStatus() MigrateStatus {
+	switch s.Type {
+	case MigrationTypeUndoSQL, MigrationTypeUndoJDBC:
+		return StatusUndone
+	case MigrationTypeDelete:
+		return StatusDeleted
+	}
+	return 0
+}
+
+// MigrationFunc 是Go函数实现的Migration, 在事务中执行. 注意MySQL的DDL会隐式提交事务.
+// 传入的ctx不会被取消, 调用方取消时正在执行的语句由KILL QUERY中断
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
 type Migration struct {
 	Name    string
 	Content string
+	// DownContent 用于回滚, 可为空
+	DownContent string
+	// Version 可为空, 如Flyway风格的Migration
+	Version string
+	// Repeatable 为true时在所有版本化的Migration之后执行, 内容变化后会重新执行
+	Repeatable bool
+	// UpFunc 不为空时代替Content执行, DownFunc 不为空时代替DownContent回滚
+	UpFunc   MigrationFunc
+	DownFunc MigrationFunc
+	// FuncChecksum 代替Content计算Go函数Migration的校验值, 修改函数的行为时需要同时修改它, 如改为新的版本标记
+	FuncChecksum string
 }
 
 type MigrationVersion []int
@@ -34,6 +115,26 @@ func ParseMigrationVersion(version string) (MigrationVersion, error) {
 	return migrationVersion, nil
 }
 
+func (m MigrationVersion) Compare(other MigrationVersion) int {
+	minVersionLen := len(m)
+	if minVersionLen > len(other) {
+		minVersionLen = len(other)
+	}
+	for t := 0; t < minVersionLen; t++ {
+		if m[t] < other[t] {
+			return -1
+		} else if m[t] > other[t] {
+			return 1
+		}
+	}
+	if len(m) < len(other) {
+		return -1
+	} else if len(m) > len(other) {
+		return 1
+	}
+	return 0
+}
+
 func (m MigrationVersion) String() string {
 	versions := make([]string, 0, len(m))
 	for _, version := range m {
@@ -43,10 +144,43 @@ func (m MigrationVersion) String() string {
 }
 
 func (m *Migration) GetContentHash() string {
-	sum := sha1.Sum([]byte(m.Content))
+	sum := sha1.Sum([]byte(m.checksumContent()))
 	return hex.EncodeToString(sum[:])
 }
 
+// GetFlywayChecksum 与Flyway的算法一致: 去掉BOM和换行符后计算CRC32
+func (m *Migration) GetFlywayChecksum() int32 {
+	content := strings.TrimPrefix(m.checksumContent(), "\ufeff")
+	content = strings.NewReplacer("\r", "", "\n", "").Replace(content)
+	return int32(crc32.ChecksumIEEE([]byte(content)))
+}
+
+// IsFunc 判断是否为Go函数实现的Migration
+func (m *Migration) IsFunc() bool {
+	return m.UpFunc != nil
+}
+
+// checksumContent 返回用于计算校验值的内容, Go函数Migration使用FuncChecksum
+func (m *Migration) checksumContent() string {
+	if m.IsFunc() {
+		return m.FuncChecksum
+	}
+	return m.Content
+}
+
+func (m *Migration) hasDown() bool {
+	return m.DownFunc != nil || m.DownContent != ""
+}
+
+// GetDescription 对Flyway风格的Migration返回文件名中的描述部分(下划线替换为空格), 否则返回名称
+func (m *Migration) GetDescription() string {
+	matches := reValidFlywayFilename.FindStringSubmatch(m.Name)
+	if len(matches) == 0 {
+		return m.Name
+	}
+	return strings.ReplaceAll(matches[4], "_", " ")
+}
+
 type SortableMigration struct {
 	M       *Migration
 	Version []int
@@ -63,19 +197,37 @@ func (s SortableMigrations) Swap(i, j int) {
 }
 
 func (s SortableMigrations) Less(i, j int) bool {
-	minVersionLen := len(s[i].Version)
-	if minVersionLen > len(s[j].Version) {
-		minVersionLen = len(s[j].Version)
+	return MigrationVersion(s[i].Version).Compare(s[j].Version) < 0
+}
+
+// findMigration 按名称或版本号(可带前缀V)查找Migration, 找不到返回-1
+func findMigration(migrations []Migration, target string) int {
+	for i := range migrations {
+		if migrations[i].Name == target {
+			return i
+		}
 	}
-	for t := 0; t < minVersionLen; t++ {
-		if s[i].Version[t] < s[j].Version[t] {
-			return true
-		} else if s[i].Version[t] > s[j].Version[t] {
-			return false
+	for i := range migrations {
+		if matchMigrationVersion(&migrations[i], target) {
+			return i
 		}
 	}
-	if len(s[i].Version) < len(s[j].Version) {
-		return true
+	return -1
+}
+
+// matchMigration 判断Migration的名称或版本号是否与target一致
+func matchMigration(migration *Migration, target string) bool {
+	return migration.Name == target || matchMigrationVersion(migration, target)
+}
+
+func matchMigrationVersion(migration *Migration, target string) bool {
+	if migration.Version == "" {
+		return false
+	}
+	targetVersion, err := ParseMigrationVersion(strings.TrimLeft(target, "vV"))
+	if err != nil {
+		return false
 	}
-	return false
+	version, err := ParseMigrationVersion(migration.Version)
+	return err == nil && version.Compare(targetVersion) == 0
 }
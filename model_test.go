@@ -0,0 +1,98 @@
+package gomigrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestMigrationVersionCompare(t *testing.T) {
+	testcases := []struct {
+		A      string
+		B      string
+		Result int
+	}{
+		{"1", "1", 0},
+		{"1", "1_1", -1},
+		{"1.2", "1_1", 1},
+		{"2", "10", -1},
+		{"1_0", "1", 1},
+	}
+	for _, testcase := range testcases {
+		a, err := ParseMigrationVersion(testcase.A)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		b, err := ParseMigrationVersion(testcase.B)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if a.Compare(b) != testcase.Result {
+			t.Errorf("compare %s with %s", testcase.A, testcase.B)
+		}
+	}
+}
+
+func TestFindMigration(t *testing.T) {
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1"},
+		{Name: "V1_1__test_table2.sql", Version: "1.1"},
+		{Name: "test_table3"},
+	}
+	if findMigration(migrations, "V1_1__test_table2.sql") != 1 {
+		t.FailNow()
+	}
+	if findMigration(migrations, "V1_1") != 1 || findMigration(migrations, "1.1") != 1 {
+		t.FailNow()
+	}
+	if findMigration(migrations, "test_table3") != 2 {
+		t.FailNow()
+	}
+	if findMigration(migrations, "V2") != -1 {
+		t.FailNow()
+	}
+}
+
+func TestMigrationGetFlywayChecksum(t *testing.T) {
+	migration := Migration{Content: "create table test_table1(\nid int\n);\n"}
+	crlfMigration := Migration{Content: "\ufeffcreate table test_table1(\r\nid int\r\n);\r\n"}
+	if migration.GetFlywayChecksum() != crlfMigration.GetFlywayChecksum() {
+		t.FailNow()
+	}
+	lines := Migration{Content: "create table test_table1(id int);"}
+	if migration.GetFlywayChecksum() != lines.GetFlywayChecksum() {
+		t.FailNow()
+	}
+	if (&Migration{}).GetFlywayChecksum() != 0 {
+		t.FailNow()
+	}
+}
+
+func TestMigrationGetDescription(t *testing.T) {
+	migration := Migration{Name: "V1_1__create_test__table.sql"}
+	if migration.GetDescription() != "create test  table" {
+		t.FailNow()
+	}
+	migration = Migration{Name: "test_table1"}
+	if migration.GetDescription() != "test_table1" {
+		t.FailNow()
+	}
+}
+
+func TestFuncMigrationChecksum(t *testing.T) {
+	up := func(ctx context.Context, tx *sql.Tx) error { return nil }
+	migration := Migration{Name: "seed", Content: "ignored", UpFunc: up, FuncChecksum: "v1"}
+	if migration.GetContentHash() != (&Migration{Content: "v1"}).GetContentHash() {
+		t.FailNow()
+	}
+	if migration.GetFlywayChecksum() != (&Migration{Content: "v1"}).GetFlywayChecksum() {
+		t.FailNow()
+	}
+	modified := migration
+	modified.FuncChecksum = "v2"
+	if migration.GetContentHash() == modified.GetContentHash() {
+		t.FailNow()
+	}
+}
@@ -1,52 +1,77 @@
 package gomigrate
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
-	"net/url"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/jedib0t/go-pretty/v6/table"
-	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/go-sql-driver/mysql"
 )
 
 type mysqlMigrateExecutor struct {
 	BaseExecutor
-	connSource string
+	db         *sql.DB
+	conn       *sql.Conn
+	ownDB      bool
 	migrations []Migration
 }
 
+// NewMySQLMigrateExecutor 使用connSource创建执行器, connSource无效时panic
+//
+// Deprecated: 使用返回错误的OpenMySQLMigrateExecutor
 func NewMySQLMigrateExecutor(connSource string) MigrationExecutor {
-	var values url.Values
-	var err error
-	if strings.Contains(connSource, "?") {
-		values, err = url.ParseQuery(connSource[strings.LastIndex(connSource, "?")+1:])
-		if err != nil {
-			panic(err)
-		}
-		connSource = connSource[0:strings.LastIndex(connSource, "?")+1]
-	} else {
-		values = make(url.Values)
-		connSource += "?"
-	}
-	values.Set("charset", "utf8")
-	values.Set("parseTime", "true")
-	values.Set("loc", "Local")
-	values.Set("multiStatements", "true")
-	connSource = connSource +values.Encode()
-	db, err := sql.Open("mysql", connSource)
+	executor, err := OpenMySQLMigrateExecutor(connSource)
 	if err != nil {
 		panic(err)
 	}
+	return executor
+}
+
+// OpenMySQLMigrateExecutor 使用dsn打开连接池, 连接池由Close关闭. dsn中的charset, loc等设置保持不变
+func OpenMySQLMigrateExecutor(dsn string, opts ...Option) (MigrationExecutor, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
 	db.SetConnMaxLifetime(time.Minute * 5)
-	db.SetMaxOpenConns(1)
+	// 第二个连接用于ctx取消时KILL QUERY
+	db.SetMaxOpenConns(2)
 	db.SetMaxIdleConns(1)
-	return &mysqlMigrateExecutor{
-		connSource: connSource,
-	}
+
+	executor := newMySQLMigrateExecutor(opts)
+	executor.db = db
+	executor.ownDB = true
+	return executor, nil
+}
+
+// NewMySQLMigrateExecutorFromDB 使用已配置好的连接池, 其DSN是否开启parseTime均可, Close不会关闭db.
+// 取消正在执行的Migration时需要db中的第二个连接发送KILL QUERY
+func NewMySQLMigrateExecutorFromDB(db *sql.DB, opts ...Option) MigrationExecutor {
+	executor := newMySQLMigrateExecutor(opts)
+	executor.db = db
+	return executor
+}
+
+// NewMySQLMigrateExecutorFromConn 所有操作都在conn上执行, Close不会关闭conn
+func NewMySQLMigrateExecutorFromConn(conn *sql.Conn, opts ...Option) MigrationExecutor {
+	executor := newMySQLMigrateExecutor(opts)
+	executor.conn = conn
+	return executor
+}
+
+func newMySQLMigrateExecutor(opts []Option) *mysqlMigrateExecutor {
+	executor := &mysqlMigrateExecutor{}
+	executor.applyOptions(opts)
+	return executor
 }
 
 func (m *mysqlMigrateExecutor) SetMigrations(migrations []Migration) {
@@ -56,19 +81,67 @@ func (m *mysqlMigrateExecutor) SetMigrations(migrations []Migration) {
 	}
 }
 
-func (m *mysqlMigrateExecutor) connectDB() (*sql.DB, error) {
-	db, err := sql.Open("mysql", m.connSource)
+func (m *mysqlMigrateExecutor) Close() error {
+	if m.ownDB {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// connectDB 获取一个独占连接, 用完后需调用release
+func (m *mysqlMigrateExecutor) connectDB(ctx context.Context) (conn *sql.Conn, release func(), err error) {
+	if m.conn != nil {
+		return m.conn, func() {}, nil
+	}
+	conn, err = m.db.Conn(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	db.SetConnMaxLifetime(time.Minute * 5)
-	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(1)
-	return db, nil
+	return conn, func() { conn.Close() }, nil
+}
+
+// utcDateTime 以UTC读写DATETIME列, 不受DSN中loc和parseTime的影响
+type utcDateTime time.Time
+
+const mysqlDateTimeLayout = "2006-01-02 15:04:05.999999"
+
+func (t utcDateTime) Value() (driver.Value, error) {
+	return time.Time(t).UTC().Format(mysqlDateTimeLayout), nil
 }
 
-func (m *mysqlMigrateExecutor) isSchemaHistoryTableExist(db *sql.DB) (bool, error) {
-	rows, err := db.Query("SHOW TABLES")
+// Scan 开启parseTime时驱动按DSN中的loc解析为time.Time, 需重新按UTC解释; 未开启时为[]byte
+func (t *utcDateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = utcDateTime{}
+		return nil
+	case time.Time:
+		*t = utcDateTime(time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC))
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("unsupported DATETIME value %T", value)
+}
+
+func (t *utcDateTime) parse(value string) error {
+	// 零值日期无法解析为time.Time
+	if strings.HasPrefix(value, "0000-00-00") {
+		*t = utcDateTime{}
+		return nil
+	}
+	parsed, err := time.ParseInLocation(mysqlDateTimeLayout, value, time.UTC)
+	if err != nil {
+		return err
+	}
+	*t = utcDateTime(parsed)
+	return nil
+}
+
+func (m *mysqlMigrateExecutor) isSchemaHistoryTableExist(ctx context.Context, db dbConn) (bool, error) {
+	rows, err := db.QueryContext(ctx, "SHOW TABLES")
 	if err != nil {
 		return false, err
 	}
@@ -88,25 +161,176 @@ func (m *mysqlMigrateExecutor) isSchemaHistoryTableExist(db *sql.DB) (bool, erro
 	return false, nil
 }
 
-func (m *mysqlMigrateExecutor) addSchemaHistory(db *sql.DB, rank int, migration Migration) error {
-	_, err := db.Exec(fmt.Sprintf("INSERT INTO `%s`(`rank`, `name`, `content`, `installed_time`) VALUES(?, ?, ?, ?)", m.GetSchemaHistoryTableName()),
-		rank,
+func (m *mysqlMigrateExecutor) rankColumn() string {
+	if m.flywayHistory {
+		return "installed_rank"
+	}
+	return "rank"
+}
+
+func (m *mysqlMigrateExecutor) addSchemaHistory(ctx context.Context, db dbConn, schemaHistory *SchemaHistory) error {
+	query, args := m.addSchemaHistoryQuery(schemaHistory)
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m *mysqlMigrateExecutor) addSchemaHistoryQuery(schemaHistory *SchemaHistory) (string, []interface{}) {
+	if m.flywayHistory {
+		return m.addFlywaySchemaHistoryQuery(schemaHistory)
+	}
+	migrationType := schemaHistory.Type
+	if migrationType == "" {
+		migrationType = MigrationTypeSQL
+		if schemaHistory.IsFunc() {
+			migrationType = MigrationTypeFunc
+		}
+		if schemaHistory.Repeatable {
+			migrationType = MigrationTypeRepeatable
+			if schemaHistory.IsFunc() {
+				migrationType = MigrationTypeRepeatableFunc
+			}
+		}
+	}
+	// 基线记录没有对应的内容, 不计算checksum
+	checksum := sql.NullString{}
+	if migrationType != MigrationTypeBaseline {
+		checksum = sql.NullString{String: m.migrationChecksum(&schemaHistory.Migration), Valid: true}
+	}
+	return fmt.Sprintf("INSERT INTO `%s`(`rank`, `name`, `type`, `version`, `description`, `checksum`, `content`, "+
+			"`installed_by`, `installed_host`, `app_version`, `installed_time`, `execution_time`, `success`, `failed_statement`) "+
+			"VALUES(?, ?, ?, ?, ?, ?, ?, COALESCE(?, SUBSTRING_INDEX(CURRENT_USER(), '@', 1)), ?, ?, ?, ?, ?, ?)", m.GetSchemaHistoryTableName()),
+		[]interface{}{
+			schemaHistory.Rank,
+			schemaHistory.Name,
+			migrationType,
+			sql.NullString{String: schemaHistory.Version, Valid: schemaHistory.Version != ""},
+			schemaHistory.getDescription(),
+			checksum,
+			m.schemaHistoryContent(&schemaHistory.Migration),
+			m.installedByArg(),
+			installedHost(),
+			m.appVersion,
+			utcDateTime(schemaHistory.InstalledTime),
+			schemaHistory.ExecutionTime.Milliseconds(),
+			schemaHistory.Success,
+			schemaHistory.FailedStatement,
+		}
+}
+
+// updateSchemaHistory 用于重新执行可重复执行的Migration后更新记录
+func (m *mysqlMigrateExecutor) updateSchemaHistory(ctx context.Context, db dbConn, schemaHistory *SchemaHistory) error {
+	query, args := m.updateSchemaHistoryQuery(schemaHistory)
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m *mysqlMigrateExecutor) updateSchemaHistoryQuery(schemaHistory *SchemaHistory) (string, []interface{}) {
+	if m.flywayHistory {
+		return m.updateFlywaySchemaHistoryQuery(schemaHistory)
+	}
+	return fmt.Sprintf("UPDATE `%s` SET `checksum` = ?, `content` = ?, `installed_by` = COALESCE(?, SUBSTRING_INDEX(CURRENT_USER(), '@', 1)), "+
+			"`installed_host` = ?, `app_version` = ?, `installed_time` = ?, `execution_time` = ?, `success` = ?, `failed_statement` = ? WHERE `rank` = ?", m.GetSchemaHistoryTableName()),
+		[]interface{}{
+			m.migrationChecksum(&schemaHistory.Migration),
+			m.schemaHistoryContent(&schemaHistory.Migration),
+			m.installedByArg(),
+			installedHost(),
+			m.appVersion,
+			utcDateTime(schemaHistory.InstalledTime),
+			schemaHistory.ExecutionTime.Milliseconds(),
+			schemaHistory.Success,
+			schemaHistory.FailedStatement,
+			schemaHistory.Rank,
+		}
+}
+
+// realignSchemaHistory 将记录与当前的Migration对齐, 用于修复修改过的Migration
+func (m *mysqlMigrateExecutor) realignSchemaHistory(ctx context.Context, db dbConn, rank int, migration *Migration) error {
+	if m.flywayHistory {
+		return m.realignFlywaySchemaHistory(ctx, db, rank, migration)
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET `name` = ?, `version` = ?, `description` = ?, `checksum` = ?, `content` = ? WHERE `rank` = ?", m.GetSchemaHistoryTableName()),
 		migration.Name,
-		migration.Content,
-		time.Now(),
+		sql.NullString{String: migration.Version, Valid: migration.Version != ""},
+		migration.GetDescription(),
+		m.migrationChecksum(migration),
+		m.schemaHistoryContent(migration),
+		rank,
 	)
 	return err
 }
 
-func (m *mysqlMigrateExecutor) getSchemaHistories(db *sql.DB) ([]SchemaHistory, error) {
-	isInit, err := m.isSchemaHistoryTableExist(db)
+func (m *mysqlMigrateExecutor) deleteSchemaHistory(ctx context.Context, db dbConn, rank int) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE `%s` = ?", m.GetSchemaHistoryTableName(), m.rankColumn()), rank)
+	return err
+}
+
+// compactSchemaHistoryRanks 重新编号rank以消除删除记录后留下的空缺
+func (m *mysqlMigrateExecutor) compactSchemaHistoryRanks(ctx context.Context, db dbConn) error {
+	schemaHistories, err := m.getSchemaHistories(ctx, db)
+	if err != nil {
+		return err
+	}
+	for i, schemaHistory := range schemaHistories {
+		if schemaHistory.Rank == i+1 {
+			continue
+		}
+		_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET `%[2]s` = ? WHERE `%[2]s` = ?", m.GetSchemaHistoryTableName(), m.rankColumn()), i+1, schemaHistory.Rank)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaHistoryColumns 为Schema History表的列, 旧版本创建的表可能缺少Default之外的列.
+// Definition 用于升级时补充列, Default 用于读取未升级的表时代替缺失的列
+var schemaHistoryColumns = []struct {
+	Name       string
+	Definition string
+	Default    string
+}{
+	{"rank", "", ""},
+	{"name", "", ""},
+	{"type", "VARCHAR(20) NOT NULL DEFAULT 'SQL' COMMENT 'migration type' AFTER `name`", "'SQL'"},
+	{"version", "VARCHAR(50) COMMENT 'migration version' AFTER `type`", "NULL"},
+	{"description", "VARCHAR(200) NOT NULL DEFAULT '' COMMENT 'migration description' AFTER `version`", "''"},
+	{"checksum", "VARCHAR(64) COMMENT 'sha1 of schema content' AFTER `description`", "NULL"},
+	{"content", "", ""},
+	{"installed_by", "VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'database user who installed the migration' AFTER `content`", "''"},
+	{"installed_host", "VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'host which installed the migration' AFTER `installed_by`", "''"},
+	{"app_version", "VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'app version which installed the migration' AFTER `installed_host`", "''"},
+	{"installed_time", "", ""},
+	{"execution_time", "INT NOT NULL DEFAULT 0 COMMENT 'execution time in milliseconds' AFTER `installed_time`", "0"},
+	{"success", "TINYINT(1) NOT NULL DEFAULT 1 COMMENT 'whether the migration succeeded' AFTER `execution_time`", "1"},
+	{"failed_statement", "INT NOT NULL DEFAULT 0 COMMENT 'failed statement, starting from 1' AFTER `success`", "0"},
+}
+
+// getSchemaHistories 只读取, 不升级旧版本创建的表, 缺失的列使用默认值
+func (m *mysqlMigrateExecutor) getSchemaHistories(ctx context.Context, db dbConn) ([]SchemaHistory, error) {
+	isInit, err := m.isSchemaHistoryTableExist(ctx, db)
 	if err != nil {
 		return nil, err
 	}
 	if !isInit {
 		return make([]SchemaHistory, 0), nil
 	}
-	rows, err := db.Query(fmt.Sprintf("SELECT `rank`, `name`, `content`, `installed_time` FROM `%s` ORDER BY `rank` ASC", m.GetSchemaHistoryTableName()))
+	if m.flywayHistory {
+		return m.getFlywaySchemaHistories(ctx, db)
+	}
+	existColumns, err := m.getSchemaHistoryColumnTypes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	selectColumns := make([]string, 0, len(schemaHistoryColumns))
+	for _, column := range schemaHistoryColumns {
+		if _, ok := existColumns[column.Name]; ok || column.Default == "" {
+			selectColumns = append(selectColumns, "`"+column.Name+"`")
+		} else {
+			selectColumns = append(selectColumns, column.Default)
+		}
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM `%s` ORDER BY `rank` ASC", strings.Join(selectColumns, ", "), m.GetSchemaHistoryTableName()))
 	if err != nil {
 		return nil, err
 	}
@@ -115,39 +339,173 @@ func (m *mysqlMigrateExecutor) getSchemaHistories(db *sql.DB) ([]SchemaHistory,
 	schemaHistories := make([]SchemaHistory, 0)
 	for rows.Next() {
 		schemaHistory := SchemaHistory{}
-		err = rows.Scan(&schemaHistory.Rank, &schemaHistory.Name, &schemaHistory.Content, &schemaHistory.InstalledTime)
+		var version, checksum, content sql.NullString
+		var installedTime utcDateTime
+		var executionTime int64
+		err = rows.Scan(&schemaHistory.Rank, &schemaHistory.Name, &schemaHistory.Type, &version, &schemaHistory.Description,
+			&checksum, &content, &schemaHistory.InstalledBy, &schemaHistory.InstalledHost, &schemaHistory.AppVersion,
+			&installedTime, &executionTime, &schemaHistory.Success, &schemaHistory.FailedStatement)
 		if err != nil {
 			return nil, err
 		}
+		schemaHistory.Version = version.String
+		schemaHistory.Checksum = checksum.String
+		schemaHistory.Content = content.String
+		schemaHistory.Repeatable = schemaHistory.Type == MigrationTypeRepeatable || schemaHistory.Type == MigrationTypeRepeatableFunc
+		schemaHistory.InstalledTime = time.Time(installedTime)
+		schemaHistory.ExecutionTime = time.Duration(executionTime) * time.Millisecond
 		schemaHistories = append(schemaHistories, schemaHistory)
 	}
 
-	return schemaHistories, err
+	return schemaHistories, rows.Err()
 }
 
 func (m *mysqlMigrateExecutor) InitSchemaHistoryTable() error {
-	db, err := m.connectDB()
+	return m.InitSchemaHistoryTableContext(context.Background())
+}
+
+func (m *mysqlMigrateExecutor) InitSchemaHistoryTableContext(ctx context.Context) error {
+	conn, release, err := m.connectDB(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
+
+	return m.initSchemaHistoryTable(ctx, conn)
+}
+
+func (m *mysqlMigrateExecutor) initSchemaHistoryTable(ctx context.Context, db dbConn) error {
+	_, err := db.ExecContext(ctx, m.createSchemaHistoryTableSQL())
+	return err
+}
 
+func (m *mysqlMigrateExecutor) createSchemaHistoryTableSQL() string {
+	if m.flywayHistory {
+		return m.createFlywaySchemaHistoryTableSQL()
+	}
 	// 建表
-	createTableSQL := strings.Join([]string{
+	return strings.Join([]string{
 		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`(", m.GetSchemaHistoryTableName()),
 		"`rank` INT(11) NOT NULL COMMENT 'rank',",
 		"`name` VARCHAR(156) NOT NULL COMMENT 'schema name',",
-		"`content` TEXT COMMENT 'schema content',",
-		"`installed_time` DATETIME NOT NULL COMMENT 'installed time',",
+		"`type` VARCHAR(20) NOT NULL DEFAULT 'SQL' COMMENT 'migration type',",
+		"`version` VARCHAR(50) COMMENT 'migration version',",
+		"`description` VARCHAR(200) NOT NULL DEFAULT '' COMMENT 'migration description',",
+		"`checksum` VARCHAR(64) COMMENT 'sha1 of schema content',",
+		"`content` MEDIUMTEXT COMMENT 'schema content',",
+		"`installed_by` VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'database user who installed the migration',",
+		"`installed_host` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'host which installed the migration',",
+		"`app_version` VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'app version which installed the migration',",
+		"`installed_time` DATETIME NOT NULL COMMENT 'installed time in UTC',",
+		"`execution_time` INT NOT NULL DEFAULT 0 COMMENT 'execution time in milliseconds',",
+		"`success` TINYINT(1) NOT NULL DEFAULT 1 COMMENT 'whether the migration succeeded',",
+		"`failed_statement` INT NOT NULL DEFAULT 0 COMMENT 'failed statement, starting from 1',",
 		"PRIMARY KEY(`rank`),",
 		"UNIQUE KEY `uniq_idx_name`(`name`)",
 		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT 'DO NOT touch this unless you know what you are doing';",
 	}, "\n")
-	_, err = db.Exec(createTableSQL)
+}
+
+// getSchemaHistoryColumnTypes 返回Schema History表的列名和小写的列类型
+func (m *mysqlMigrateExecutor) getSchemaHistoryColumnTypes(ctx context.Context, db dbConn) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SHOW COLUMNS FROM `%s`", m.GetSchemaHistoryTableName()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnTypes := make(map[string]string)
+	for rows.Next() {
+		values := make([]sql.RawBytes, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		columnTypes[string(values[0])] = strings.ToLower(string(values[1]))
+	}
+	return columnTypes, rows.Err()
+}
+
+// upgradeSchemaHistoryTable 为旧版本创建的表补充缺失的列, 并将content改为MEDIUMTEXT.
+// 升级会执行DDL, 只能在持有Migration锁的写操作中调用
+func (m *mysqlMigrateExecutor) upgradeSchemaHistoryTable(ctx context.Context, db dbConn) error {
+	statements, err := m.schemaHistoryUpgradeSQL(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err = db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaHistoryUpgradeSQL 返回升级旧版本创建的表的语句, 表不存在或已是最新时为空
+func (m *mysqlMigrateExecutor) schemaHistoryUpgradeSQL(ctx context.Context, db dbConn) ([]string, error) {
+	if m.flywayHistory {
+		return nil, nil
+	}
+	isInit, err := m.isSchemaHistoryTableExist(ctx, db)
+	if err != nil || !isInit {
+		return nil, err
+	}
+	existColumns, err := m.getSchemaHistoryColumnTypes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := make([]string, 0)
+	for _, column := range schemaHistoryColumns {
+		if _, ok := existColumns[column.Name]; ok || column.Definition == "" {
+			continue
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", m.GetSchemaHistoryTableName(), column.Name, column.Definition))
+	}
+	// TEXT最多64KB, 无法记录较大的Migration
+	if contentType := existColumns["content"]; contentType == "text" || contentType == "tinytext" {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN `content` MEDIUMTEXT COMMENT 'schema content'", m.GetSchemaHistoryTableName()))
+	}
+	if _, ok := existColumns["checksum"]; !ok {
+		// 旧记录只有完整内容, 根据内容补充checksum, 与GetContentHash的算法一致
+		statements = append(statements, fmt.Sprintf("UPDATE `%s` SET `checksum` = SHA1(`content`) WHERE `checksum` IS NULL AND `content` IS NOT NULL AND `type` <> '%s'",
+			m.GetSchemaHistoryTableName(), MigrationTypeBaseline))
+	}
+	return statements, nil
+}
+
+func (m *mysqlMigrateExecutor) CheckMigrations() error {
+	return m.CheckMigrationsContext(context.Background())
+}
+
+func (m *mysqlMigrateExecutor) CheckMigrationsContext(ctx context.Context) error {
+	conn, release, err := m.connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	_, err = m.checkMigrations(ctx, conn, m.target)
 	return err
 }
 
-func (m *mysqlMigrateExecutor) CheckMigrations() (err error) {
+func (m *mysqlMigrateExecutor) loadMigrateInfos(ctx context.Context, db dbConn, target string) ([]MigrationInfo, error) {
+	schemaHistories, err := m.getSchemaHistories(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	migrateInfos := m.buildMigrateInfos(schemaHistories, m.migrations)
+	return migrateInfos, applyTarget(migrateInfos, m.migrations, target)
+}
+
+func (m *mysqlMigrateExecutor) checkMigrations(ctx context.Context, db dbConn, target string) (migrateInfos []MigrationInfo, err error) {
 	// 检查存不存在重复的Migration名称
 	if m.migrations != nil {
 		migrationNameSet := make(map[string]int)
@@ -164,205 +522,708 @@ func (m *mysqlMigrateExecutor) CheckMigrations() (err error) {
 				migrationNameSet[migration.Name] = 2
 			}
 		}
-		if err != nil {
-			return err
+		// Go函数Migration的校验值无法从内容计算, 必须指定
+		for _, migration := range m.migrations {
+			if err == nil && migration.IsFunc() && migration.FuncChecksum == "" {
+				err = fmt.Errorf("%w: %s has no checksum", ErrInvalidMigrations, migration.Name)
+				break
+			}
 		}
 	}
+	if err != nil {
+		m.getLogger().Warn("migration check failed", "error", err)
+		return nil, err
+	}
+
+	migrateInfos, err = m.loadMigrateInfos(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkMigrateInfos(migrateInfos); err != nil {
+		m.getLogger().Warn("migration check failed", "error", err)
+	}
+	return migrateInfos, err
+}
+
+func (m *mysqlMigrateExecutor) GetMigrationInfos() ([]MigrationInfo, error) {
+	return m.GetMigrationInfosContext(context.Background())
+}
+
+func (m *mysqlMigrateExecutor) GetMigrationInfosContext(ctx context.Context) ([]MigrationInfo, error) {
+	db, release, err := m.connectDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	db, err := m.connectDB()
+	var migrateInfos []MigrationInfo
+	err = m.withCallbacks(ctx, db, BeforeInfo, AfterInfo, AfterInfoError, nil, func() error {
+		migrateInfos, err = m.loadMigrateInfos(ctx, db, m.target)
+		return err
+	})
+	return migrateInfos, err
+}
+
+func (m *mysqlMigrateExecutor) ShowMigrations() error {
+	return m.ShowMigrationsContext(context.Background())
+}
+
+func (m *mysqlMigrateExecutor) ShowMigrationsContext(ctx context.Context) error {
+	migrateInfos, err := m.GetMigrationInfosContext(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	return RenderMigrationInfos(m.getOutput(), migrateInfos, m.renderOptions)
+}
 
-	schemaHistories, err := m.getSchemaHistories(db)
-	if len(schemaHistories) == 0 {
-		return nil
+func (m *mysqlMigrateExecutor) InstallMigrations() error {
+	return m.InstallMigrationsContext(context.Background())
+}
+
+func (m *mysqlMigrateExecutor) InstallMigrationsContext(ctx context.Context) error {
+	return m.installMigrations(ctx, m.target)
+}
+
+func (m *mysqlMigrateExecutor) InstallMigrationsTo(target string) error {
+	return m.InstallMigrationsToContext(context.Background(), target)
+}
+
+// InstallMigrationsToContext 只安装到target为止, target为Migration名称或版本号, 之后的Migration为ABOVE TARGET
+func (m *mysqlMigrateExecutor) InstallMigrationsToContext(ctx context.Context, target string) error {
+	if target == "" {
+		return fmt.Errorf("%w: empty target", ErrMigrationNotFound)
 	}
+	return m.installMigrations(ctx, target)
+}
 
-	for i, schemaHistory := range schemaHistories {
-		if schemaHistory.Rank != i+1 {
-			return ErrBrokenSchemaHistory
-		}
+func (m *mysqlMigrateExecutor) installMigrations(ctx context.Context, target string) error {
+	db, release, err := m.connectDB(ctx)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	if len(schemaHistories) > len(m.migrations) {
-		return ErrMigrationMissing
+	unlock, err := m.lock(ctx, db)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	for i, schemaHistory := range schemaHistories {
-		if schemaHistory.Name != m.migrations[i].Name || schemaHistory.Content != m.migrations[i].Content {
-			return ErrMigrationModified
-		}
+	if err = m.upgradeSchemaHistoryTable(ctx, db); err != nil {
+		return err
 	}
-	return nil
+
+	return m.withCallbacks(ctx, db, BeforeMigrate, AfterMigrate, AfterMigrateError, nil, func() error {
+		return m.migrate(ctx, db, target)
+	})
 }
 
-func (m *mysqlMigrateExecutor) ShowMigrations() error {
-	db, err := m.connectDB()
+func (m *mysqlMigrateExecutor) migrate(ctx context.Context, db *sql.Conn, target string) error {
+	migrateInfos, err := m.checkMigrations(ctx, db, target)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
-	schemaHistories, err := m.getSchemaHistories(db)
-	if err != nil && !errors.Is(err, ErrBrokenSchemaHistory) {
+	isInit, err := m.isSchemaHistoryTableExist(ctx, db)
+	if err != nil {
 		return err
 	}
+	if !isInit {
+		err = m.initSchemaHistoryTable(ctx, db)
+		if err != nil {
+			return err
+		}
+	}
 
-	t := table.NewWriter()
-	t.AppendHeader(table.Row{"Rank", "Schema Name", "Migration Name", "Installed Time", "Status"})
+	for _, pending := range pendingMigrations(migrateInfos, m.flywayHistory) {
+		// 每个Migration执行前检查是否已取消
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		migration, rank, reinstall := pending.Migration, pending.Rank, pending.Reinstall
+		err = m.withCallbacks(ctx, db, BeforeEachMigrate, AfterEachMigrate, AfterEachMigrateError, migration, func() error {
+			return m.installMigration(ctx, db, migration, rank, reinstall, 1)
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	colorError := text.FgRed
-	colorSuccess := text.FgGreen
+	return nil
+}
 
-	schemaHistoryMap := make(map[int]*SchemaHistory)
-	maxRank := 0
-	if schemaHistories != nil {
-		for i, schemaHistory := range schemaHistories {
-			if maxRank < schemaHistory.Rank {
-				maxRank = schemaHistory.Rank
-			}
-			schemaHistoryMap[schemaHistory.Rank] = &schemaHistories[i]
-		}
+// installMigration 从第from条语句(从1开始)执行Migration并写入Schema History, update为true时更新rank对应的记录.
+// MySQL的DDL不能回滚, 失败时记录失败的语句, 以便从该语句继续执行
+func (m *mysqlMigrateExecutor) installMigration(ctx context.Context, db dbConn, migration *Migration, rank int, update bool, from int) error {
+	m.getLogger().Info("installing migration", "migration", migration.Name, "rank", rank, "from_statement", from)
+	startTime := time.Now()
+	execCtx, stopKill := m.killQueryOnCancel(ctx, db)
+	var execErr *MigrationError
+	if migration.IsFunc() {
+		execErr = execMigrationFunc(execCtx, db, migration.Name, migration.UpFunc)
+	} else {
+		execErr = execSQLStatements(execCtx, db, migration.Name, migration.Content, from)
 	}
-	// 计算需要遍历的最大值
-	maxLen := maxRank
-	if maxLen < len(m.migrations) {
-		maxLen = len(m.migrations)
+	stopKill()
+	failedStatement := 0
+	if execErr != nil {
+		failedStatement = execErr.Statement
 	}
 
-	migrateInfos := make([]struct {
-		SchemaHistory *SchemaHistory
-		Migration     *Migration
-		Status        MigrateStatus
-	}, maxLen)
-	for i := 0; i < maxLen; i++ {
-		migrateInfos[i].SchemaHistory = schemaHistoryMap[i+1]
-		if i < len(m.migrations) {
-			migrateInfos[i].Migration = &m.migrations[i]
+	schemaHistory := &SchemaHistory{
+		Migration:       *migration,
+		Rank:            rank,
+		InstalledTime:   time.Now().UTC(),
+		ExecutionTime:   time.Since(startTime),
+		Success:         execErr == nil,
+		FailedStatement: failedStatement,
+	}
+	// 执行失败可能是因为ctx已取消, 仍然需要记录失败
+	recordCtx := ctx
+	if execErr != nil {
+		recordCtx = context.Background()
+	}
+	// 语句由KILL QUERY中断, 连接和连接上的Migration锁仍然有效, 失败在释放锁之前记录
+	var err error
+	if update {
+		err = m.updateSchemaHistory(recordCtx, db, schemaHistory)
+	} else {
+		err = m.addSchemaHistory(recordCtx, db, schemaHistory)
+	}
+	if err != nil {
+		m.getLogger().Error("failed to write schema history", "migration", migration.Name, "rank", rank, "error", err)
+	} else {
+		m.getLogger().Debug("schema history written", "migration", migration.Name, "rank", rank, "success", schemaHistory.Success)
+	}
+	if execErr != nil {
+		m.getLogger().Error("migration failed", "migration", migration.Name, "rank", rank,
+			"statement", execErr.Statement, "duration", schemaHistory.ExecutionTime, "error", execErr.Err)
+		return execErr
+	}
+	if err == nil {
+		m.getLogger().Info("installed migration", "migration", migration.Name, "rank", rank, "duration", schemaHistory.ExecutionTime)
+	}
+	return err
+}
+
+const (
+	// killQueryTimeout ctx取消后发送KILL QUERY的超时时间
+	killQueryTimeout = 10 * time.Second
+	// killQueryInterval ctx取消后重复KILL QUERY的间隔, 避免取消时恰好在两条语句之间
+	killQueryInterval = 100 * time.Millisecond
+)
+
+// detachedContext 保留ctx中的值但不会被取消, 驱动不会因ctx取消而关闭连接
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// canceledErr 返回ctx的取消错误, detachedContext返回原ctx的取消错误
+func canceledErr(ctx context.Context) error {
+	if detached, ok := ctx.(detachedContext); ok {
+		return detached.Context.Err()
+	}
+	return ctx.Err()
+}
+
+// killQueryOnCancel 返回执行Migration使用的ctx, ctx取消时通过连接池中的另一个连接KILL QUERY db上正在执行的语句,
+// 返回的stop需在语句执行完后调用.
+// 驱动在ctx取消时会关闭连接, 服务端仍会把正在执行的语句(如ALTER TABLE)执行完, 连接上的Migration锁也随之释放,
+// 因此执行时使用不会被取消的ctx, 只中断语句而保留连接, 失败可以在持有锁时记录.
+// 只有一个*sql.Conn时无法发送KILL QUERY, 仍使用ctx
+func (m *mysqlMigrateExecutor) killQueryOnCancel(ctx context.Context, db dbConn) (execCtx context.Context, stop func()) {
+	if m.db == nil {
+		return ctx, func() {}
+	}
+	var connectionID int64
+	if err := db.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&connectionID); err != nil {
+		return ctx, func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
 		}
-		if i+1 <= maxRank {
-			if i < len(m.migrations) {
-				if schemaHistoryMap[i+1] == nil {
-					migrateInfos[i].Status = StatusBrokenSchemaHistory
-				} else {
-					if schemaHistoryMap[i+1].Name != m.migrations[i].Name || schemaHistoryMap[i+1].Content != m.migrations[i].Content {
-						migrateInfos[i].Status = StatusMigrationModified
-					} else {
-						migrateInfos[i].Status = StatusInstalled
-					}
-				}
-			} else {
-				migrateInfos[i].Status = StatusMigrationMissing
+		ticker := time.NewTicker(killQueryInterval)
+		defer ticker.Stop()
+		for warned := false; ; {
+			killCtx, cancel := context.WithTimeout(context.Background(), killQueryTimeout)
+			_, err := m.db.ExecContext(killCtx, fmt.Sprintf("KILL QUERY %d", connectionID))
+			cancel()
+			if err != nil && !warned {
+				warned = true
+				m.getLogger().Warn("failed to kill query", "connection_id", connectionID, "error", err)
+			} else if err == nil {
+				m.getLogger().Debug("killed query", "connection_id", connectionID)
 			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return detachedContext{ctx}, func() {
+		close(done)
+		<-finished
+	}
+}
 
-		} else {
-			migrateInfos[i].Status = StatusReadyToInstall
+// execSQLStatements 从第from条语句(从1开始)逐条执行content中的语句
+func execSQLStatements(ctx context.Context, db dbConn, name string, content string, from int) *MigrationError {
+	statements := splitSQLStatements(content)
+	if from < 1 {
+		from = 1
+	}
+	for i := from - 1; i < len(statements); i++ {
+		err := canceledErr(ctx)
+		if err == nil {
+			_, err = db.ExecContext(ctx, statements[i].SQL)
+			// 被KILL QUERY中断时返回取消的原因
+			if cause := canceledErr(ctx); err != nil && cause != nil {
+				err = fmt.Errorf("%w: %v", cause, err)
+			}
+		}
+		if err != nil {
+			return &MigrationError{Name: name, Statement: i + 1, SQL: statements[i].SQL, Line: statements[i].Line, Err: err}
+		}
+	}
+	return nil
+}
+
+// execMigrationFunc 在事务中执行Go函数Migration, 函数返回错误时回滚事务
+func execMigrationFunc(ctx context.Context, db dbConn, name string, migrationFunc MigrationFunc) *MigrationError {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return &MigrationError{Name: name, Statement: 1, Err: err}
+	}
+	if err = migrationFunc(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		if cause := canceledErr(ctx); cause != nil {
+			err = fmt.Errorf("%w: %v", cause, err)
 		}
+		return &MigrationError{Name: name, Statement: 1, Err: err}
 	}
+	if err = tx.Commit(); err != nil {
+		return &MigrationError{Name: name, Statement: 1, Err: err}
+	}
+	return nil
+}
 
-	hasMissingMigration := false
-	hasModifiedMigration := false
-	hasBrokenSchema := false
-	for _, migrateInfo := range migrateInfos {
-		rankText := "-"
-		schemaNameText := ""
-		migrationNameText := ""
-		installedTimeText := "-"
+func (m *mysqlMigrateExecutor) ResumeFailedMigration() error {
+	return m.ResumeFailedMigrationContext(context.Background())
+}
 
-		statusText := colorError.Sprint("UNKNOWN")
-		if migrateInfo.Status == StatusInstalled {
-			statusText = colorSuccess.Sprint("INSTALLED")
-		} else if migrateInfo.Status == StatusReadyToInstall {
-			statusText = colorSuccess.Sprint("READY TO INSTALL")
-		} else if migrateInfo.Status == StatusMigrationMissing {
-			hasMissingMigration = true
-			statusText = colorError.Sprint("MIGRATION MISSING")
-		} else if migrateInfo.Status == StatusMigrationModified {
-			hasModifiedMigration = true
-			statusText = colorError.Sprint("MIGRATION MODIFIED")
-		} else if migrateInfo.Status == StatusBrokenSchemaHistory {
-			hasBrokenSchema = true
-			statusText = colorError.Sprint("SCHEMA BROKEN")
+// ResumeFailedMigrationContext 从失败的语句开始继续执行失败的Migration, 失败的语句可以先在Migration中修改
+func (m *mysqlMigrateExecutor) ResumeFailedMigrationContext(ctx context.Context) error {
+	return m.fixFailedMigration(ctx, func(db dbConn, failedInfo *MigrationInfo) error {
+		// Flyway格式的Schema History和Flyway写入的记录没有失败语句的序号, 无法确定从哪条语句继续
+		if failedInfo.SchemaHistory.FailedStatement < 1 && !failedInfo.Migration.IsFunc() {
+			return fmt.Errorf("%w: %s, finish it by hand and call MarkFailedMigrationFixed", ErrFailedStatementUnknown, failedInfo.SchemaName)
 		}
-		if migrateInfo.SchemaHistory != nil {
-			rankText = fmt.Sprint(migrateInfo.SchemaHistory.Rank)
-			schemaNameText = migrateInfo.SchemaHistory.Name
-			installedTimeText = migrateInfo.SchemaHistory.InstalledTime.Format("2006-01-02 15:04:05")
+		return m.installMigration(ctx, db, failedInfo.Migration, failedInfo.Rank, true, failedInfo.SchemaHistory.FailedStatement)
+	})
+}
+
+func (m *mysqlMigrateExecutor) MarkFailedMigrationFixed() error {
+	return m.MarkFailedMigrationFixedContext(context.Background())
+}
+
+// MarkFailedMigrationFixedContext 将失败的Migration标记为成功, 用于已经手动完成剩余语句的情况
+func (m *mysqlMigrateExecutor) MarkFailedMigrationFixedContext(ctx context.Context) error {
+	return m.fixFailedMigration(ctx, func(db dbConn, failedInfo *MigrationInfo) error {
+		return m.updateSchemaHistory(ctx, db, &SchemaHistory{
+			Migration:     *failedInfo.Migration,
+			Rank:          failedInfo.Rank,
+			InstalledTime: time.Now().UTC(),
+			Success:       true,
+		})
+	})
+}
+
+func (m *mysqlMigrateExecutor) fixFailedMigration(ctx context.Context, fixFunc func(db dbConn, failedInfo *MigrationInfo) error) error {
+	db, release, err := m.connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	unlock, err := m.lock(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err = m.upgradeSchemaHistoryTable(ctx, db); err != nil {
+		return err
+	}
+
+	migrateInfos, err := m.checkMigrations(ctx, db, "")
+	if err != nil && !errors.Is(err, ErrMigrationFailed) {
+		return err
+	}
+	for i := range migrateInfos {
+		if migrateInfos[i].Status != StatusFailed {
+			continue
 		}
-		if migrateInfo.Migration != nil {
-			migrationNameText = migrateInfo.Migration.Name
+		if migrateInfos[i].Migration == nil {
+			return fmt.Errorf("%w: %s", ErrMigrationMissing, migrateInfos[i].SchemaName)
 		}
-		t.AppendRow([]interface{}{rankText, schemaNameText, migrationNameText, installedTimeText, statusText})
+		return fixFunc(db, &migrateInfos[i])
 	}
+	return ErrNoFailedMigration
+}
+
+func (m *mysqlMigrateExecutor) Rollback(n int) error {
+	return m.RollbackContext(context.Background(), n)
+}
 
-	helpTips := "all is well"
-	if hasMissingMigration || hasModifiedMigration || hasBrokenSchema {
-		tips := make([]string, 0)
-		if hasMissingMigration {
-			tips = append(tips, "to fix MIGRATION MISSING: provide the missing migrations")
+// RollbackContext 回滚最后安装的n个Migration
+func (m *mysqlMigrateExecutor) RollbackContext(ctx context.Context, n int) error {
+	return m.rollback(ctx, func(installedInfos []*MigrationInfo) (int, error) {
+		if n < 0 || n > len(installedInfos) {
+			return 0, fmt.Errorf("%w: cannot rollback %d of %d installed migrations", ErrMigrationNotFound, n, len(installedInfos))
 		}
-		if hasModifiedMigration {
-			tips = append(tips, "to fix installed MIGRATION MODIFIED: recovery the installed but modified migrations. \n\tPlease DO NOT modify installed migrations")
+		return len(installedInfos) - n, nil
+	})
+}
+
+func (m *mysqlMigrateExecutor) RollbackTo(target string) error {
+	return m.RollbackToContext(context.Background(), target)
+}
+
+// RollbackToContext 回滚target之后安装的所有Migration, target为Migration名称或版本号, target本身不回滚.
+// target不会被当作rank, 按rank回滚使用RollbackToRank
+func (m *mysqlMigrateExecutor) RollbackToContext(ctx context.Context, target string) error {
+	return m.rollback(ctx, func(installedInfos []*MigrationInfo) (int, error) {
+		installedMigrations := make([]Migration, len(installedInfos))
+		for i, installedInfo := range installedInfos {
+			installedMigrations[i] = *installedInfo.Migration
 		}
-		if hasBrokenSchema {
-			tips = append(tips, "to fix SCHEMA BROKEN: no cure (yet?:))")
+		index := findMigration(installedMigrations, target)
+		if index < 0 {
+			return 0, fmt.Errorf("%w: %s is not installed", ErrMigrationNotFound, target)
 		}
+		return index + 1, nil
+	})
+}
 
-		for i := range tips {
-			tips[i] = fmt.Sprintf("(%d) %s", i+1, tips[i])
-		}
-		helpTips = strings.Join(tips, "\n")
-	}
+func (m *mysqlMigrateExecutor) RollbackToRank(rank int) error {
+	return m.RollbackToRankContext(context.Background(), rank)
+}
 
-	fmt.Println(t.Render())
-	fmt.Println(helpTips)
-	return nil
+// RollbackToRankContext 回滚rank之后安装的所有Migration, rank须为已安装的版本化Migration的rank, 其本身不回滚
+func (m *mysqlMigrateExecutor) RollbackToRankContext(ctx context.Context, rank int) error {
+	return m.rollback(ctx, func(installedInfos []*MigrationInfo) (int, error) {
+		for i, installedInfo := range installedInfos {
+			if installedInfo.Rank == rank {
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: rank %d is not an installed migration", ErrMigrationNotFound, rank)
+	})
 }
 
-func (m *mysqlMigrateExecutor) InstallMigrations() error {
-	err := m.CheckMigrations()
+// rollback 按rank倒序回滚, 只保留前keep个已安装的版本化Migration
+func (m *mysqlMigrateExecutor) rollback(ctx context.Context, keepFunc func(installedInfos []*MigrationInfo) (keep int, err error)) error {
+	db, release, err := m.connectDB(ctx)
 	if err != nil {
 		return err
 	}
+	defer release()
 
-	db, err := m.connectDB()
+	unlock, err := m.lock(ctx, db)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer unlock()
+
+	if err = m.upgradeSchemaHistoryTable(ctx, db); err != nil {
+		return err
+	}
 
-	isInit, err := m.isSchemaHistoryTableExist(db)
+	return m.withCallbacks(ctx, db, BeforeUndo, AfterUndo, AfterUndoError, nil, func() error {
+		return m.undo(ctx, db, keepFunc)
+	})
+}
+
+func (m *mysqlMigrateExecutor) undo(ctx context.Context, db *sql.Conn, keepFunc func(installedInfos []*MigrationInfo) (keep int, err error)) error {
+	migrateInfos, err := m.checkMigrations(ctx, db, "")
 	if err != nil {
 		return err
 	}
-	if !isInit {
-		err = m.InitSchemaHistoryTable()
+	installedInfos := installedMigrateInfos(migrateInfos)
+	keep, err := keepFunc(installedInfos)
+	if err != nil {
+		return err
+	}
+
+	// 执行前检查所有回滚脚本是否存在
+	var missingNames []string
+	for _, installedInfo := range installedInfos[keep:] {
+		if !installedInfo.Migration.hasDown() {
+			missingNames = append(missingNames, installedInfo.Migration.Name)
+		}
+	}
+	if len(missingNames) > 0 {
+		return fmt.Errorf("%w: %s", ErrDownMigrationMissing, strings.Join(missingNames, ", "))
+	}
+
+	for i := len(installedInfos) - 1; i >= keep; i-- {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		installedInfo := installedInfos[i]
+		err = m.withCallbacks(ctx, db, BeforeEachUndo, AfterEachUndo, AfterEachUndoError, installedInfo.Migration, func() error {
+			return m.undoMigration(ctx, db, installedInfo)
+		})
 		if err != nil {
 			return err
 		}
 	}
-	schemaHistories, err := m.getSchemaHistories(db)
+	return m.compactSchemaHistoryRanks(ctx, db)
+}
+
+// undoMigration 执行回滚脚本并删除对应的Schema History
+func (m *mysqlMigrateExecutor) undoMigration(ctx context.Context, db dbConn, installedInfo *MigrationInfo) error {
+	migration := installedInfo.Migration
+	m.getLogger().Info("rolling back migration", "migration", migration.Name, "rank", installedInfo.Rank)
+	startTime := time.Now()
+	execCtx, stopKill := m.killQueryOnCancel(ctx, db)
+	var execErr *MigrationError
+	if migration.DownFunc != nil {
+		execErr = execMigrationFunc(execCtx, db, migration.Name, migration.DownFunc)
+	} else {
+		execErr = execSQLStatements(execCtx, db, migration.Name, migration.DownContent, 1)
+	}
+	stopKill()
+	if execErr != nil {
+		m.getLogger().Error("rollback failed", "migration", migration.Name, "rank", installedInfo.Rank, "error", execErr.Err)
+		return execErr
+	}
+	if err := m.deleteSchemaHistory(ctx, db, installedInfo.SchemaHistory.Rank); err != nil {
+		return err
+	}
+	m.getLogger().Info("rolled back migration", "migration", migration.Name, "rank", installedInfo.Rank, "duration", time.Since(startTime))
+	return nil
+}
+
+func (m *mysqlMigrateExecutor) Baseline(version string, description string) error {
+	return m.BaselineContext(context.Background(), version, description)
+}
+
+// BaselineContext 为已有表结构的数据库建立基线, 版本号不大于version的Migration视为已安装
+func (m *mysqlMigrateExecutor) BaselineContext(ctx context.Context, version string, description string) error {
+	db, release, err := m.connectDB(ctx)
 	if err != nil {
 		return err
 	}
-	uninstallMigrations := make([]Migration, 0)
-	if m.migrations != nil {
-		uninstallMigrations = m.migrations[len(schemaHistories):]
+	defer release()
+
+	unlock, err := m.lock(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err = m.upgradeSchemaHistoryTable(ctx, db); err != nil {
+		return err
 	}
 
-	baseRank := len(schemaHistories)
-	for i, uninstallMigration := range uninstallMigrations {
-		_, err = db.Exec(uninstallMigration.Content)
+	return m.withCallbacks(ctx, db, BeforeBaseline, AfterBaseline, AfterBaselineError, nil, func() error {
+		schemaHistories, err := m.getSchemaHistories(ctx, db)
 		if err != nil {
 			return err
 		}
-		err = m.addSchemaHistory(db, baseRank+i+1, uninstallMigration)
+		if len(schemaHistories) > 0 {
+			return fmt.Errorf("%w: cannot baseline", ErrSchemaHistoryNotEmpty)
+		}
+		err = m.initSchemaHistoryTable(ctx, db)
 		if err != nil {
 			return err
 		}
+		m.getLogger().Info("baseline schema history", "version", version, "description", description)
+		return m.addSchemaHistory(ctx, db, newBaselineSchemaHistory(version, description, m.flywayHistory))
+	})
+}
+
+func (m *mysqlMigrateExecutor) Repair(options RepairOptions) (*RepairReport, error) {
+	return m.RepairContext(context.Background(), options)
+}
+
+// RepairContext 修复 SCHEMA BROKEN 和 MIGRATION MODIFIED, 并打印修复报告
+func (m *mysqlMigrateExecutor) RepairContext(ctx context.Context, options RepairOptions) (*RepairReport, error) {
+	db, release, err := m.connectDB(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
+	unlock, err := m.lock(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err = m.upgradeSchemaHistoryTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var report *RepairReport
+	err = m.withCallbacks(ctx, db, BeforeRepair, AfterRepair, AfterRepairError, nil, func() error {
+		schemaHistories, err := m.getSchemaHistories(ctx, db)
+		if err != nil {
+			return err
+		}
+		report = planRepair(schemaHistories, m.migrations, m.buildMigrateInfos, options, m.flywayHistory)
+		m.getLogger().Info("repair schema history", "dry_run", options.DryRun, "removed", len(report.Removed),
+			"renumbered", len(report.Renumbered), "realigned", len(report.Realigned))
+		if options.DryRun {
+			return nil
+		}
+		return m.applyRepair(ctx, db, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(m.getOutput(), report.String())
+	return report, nil
+}
+
+func (m *mysqlMigrateExecutor) applyRepair(ctx context.Context, db dbConn, report *RepairReport) error {
+	for _, schemaHistory := range report.Removed {
+		err := m.deleteSchemaHistory(ctx, db, schemaHistory.Rank)
+		if err != nil {
+			return err
+		}
+	}
+	if len(report.Renumbered) > 0 {
+		err := m.compactSchemaHistoryRanks(ctx, db)
+		if err != nil {
+			return err
+		}
+	}
+	for _, realigned := range report.Realigned {
+		err := m.realignSchemaHistory(ctx, db, realigned.Rank, &realigned.Migration)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (m *mysqlMigrateExecutor) Plan() (*MigrationPlan, error) {
+	return m.PlanContext(context.Background())
+}
+
+// PlanContext 返回待安装的Migration, 以及安装时写入Schema History的语句
+func (m *mysqlMigrateExecutor) PlanContext(ctx context.Context) (*MigrationPlan, error) {
+	db, release, err := m.connectDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	migrateInfos, err := m.checkMigrations(ctx, db, m.target)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &MigrationPlan{Migrations: make([]PlannedMigration, 0)}
+	isInit, err := m.isSchemaHistoryTableExist(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if !isInit {
+		plan.InitSQL = m.createSchemaHistoryTableSQL()
+	}
+	// 旧版本创建的表缺少写入记录所需的列, 脚本中先升级
+	plan.UpgradeSQL, err = m.schemaHistoryUpgradeSQL(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, pending := range pendingMigrations(migrateInfos, m.flywayHistory) {
+		schemaHistory := &SchemaHistory{
+			Migration: *pending.Migration,
+			Rank:      pending.Rank,
+			Success:   true,
+		}
+		var query string
+		var args []interface{}
+		if pending.Reinstall {
+			query, args = m.updateSchemaHistoryQuery(schemaHistory)
+		} else {
+			query, args = m.addSchemaHistoryQuery(schemaHistory)
+		}
+		historySQL, err := interpolateMySQLQuery(query, args)
+		if err != nil {
+			return nil, err
+		}
+		plan.Migrations = append(plan.Migrations, PlannedMigration{
+			Rank:        pending.Rank,
+			Name:        pending.Migration.Name,
+			ContentHash: pending.Migration.GetContentHash(),
+			SQL:         pending.Migration.Content,
+			HistorySQL:  historySQL,
+			Func:        pending.Migration.IsFunc(),
+		})
+	}
+	return plan, nil
+}
+
+// interpolateMySQLQuery 将参数替换为字面量, 时间参数替换为NOW()或UTC_TIMESTAMP(), 即执行脚本的时间
+func interpolateMySQLQuery(query string, args []interface{}) (string, error) {
+	var builder strings.Builder
+	argIndex := 0
+	for _, c := range query {
+		if c != '?' {
+			builder.WriteRune(c)
+			continue
+		}
+		if argIndex >= len(args) {
+			return "", fmt.Errorf("missing argument %d for query: %s", argIndex+1, query)
+		}
+		switch arg := args[argIndex].(type) {
+		case nil:
+			builder.WriteString("NULL")
+		case int, int32, int64:
+			builder.WriteString(fmt.Sprint(arg))
+		case bool:
+			if arg {
+				builder.WriteString("1")
+			} else {
+				builder.WriteString("0")
+			}
+		case string:
+			builder.WriteString(quoteMySQLString(arg))
+		case sql.NullString:
+			if arg.Valid {
+				builder.WriteString(quoteMySQLString(arg.String))
+			} else {
+				builder.WriteString("NULL")
+			}
+		case time.Time:
+			builder.WriteString("NOW()")
+		case utcDateTime:
+			builder.WriteString("UTC_TIMESTAMP()")
+		default:
+			return "", fmt.Errorf("unsupported argument type %T", arg)
+		}
+		argIndex++
+	}
+	return builder.String(), nil
+}
+
+func quoteMySQLString(s string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "'", "\\'", "\x00", "\\0", "\n", "\\n", "\r", "\\r", "\x1a", "\\Z")
+	return "'" + replacer.Replace(s) + "'"
+}
@@ -1,9 +1,14 @@
 package gomigrate
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 const mysqlTestSource = "root:123456@tcp(127.0.0.1:3306)/gomigrate_test?charset=utf8"
@@ -43,15 +48,16 @@ func insertMySQLTestdata(t *testing.T) (*mysqlMigrateExecutor, *sql.DB, func())
 
 	// 连接数据库
 	mysqlExecutor := executor.(*mysqlMigrateExecutor)
-	db, err := mysqlExecutor.connectDB()
-	if err != nil {
-		t.Error(err)
-		t.FailNow()
-	}
+	db := mysqlExecutor.db
 
 	// 添加测试数据
 	for i, testMigration := range mysqlTestMigrations {
-		err = mysqlExecutor.addSchemaHistory(db, i+1, testMigration)
+		err = mysqlExecutor.addSchemaHistory(context.Background(), db, &SchemaHistory{
+			Migration:     testMigration,
+			Rank:          i + 1,
+			InstalledTime: time.Now(),
+			Success:       true,
+		})
 		if err != nil {
 			t.Error(err)
 			t.FailNow()
@@ -66,7 +72,7 @@ func insertMySQLTestdata(t *testing.T) (*mysqlMigrateExecutor, *sql.DB, func())
 		db.Exec("DROP TABLE `test_test3`")
 		db.Exec("DROP TABLE `test_test4`")
 		db.Exec("DROP TABLE `test_test5`")
-		db.Close()
+		executor.Close()
 	}
 	return mysqlExecutor, db, clearFunc
 }
@@ -76,7 +82,7 @@ func TestGetSchemaHistories(t *testing.T) {
 	defer clear()
 
 	// 获取数据并检验
-	schemaHistories, err := executor.getSchemaHistories(db)
+	schemaHistories, err := executor.getSchemaHistories(context.Background(), db)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -98,7 +104,7 @@ func TestGetSchemaHistories(t *testing.T) {
 		t.Error(err)
 		t.FailNow()
 	}
-	schemaHistories, err = executor.getSchemaHistories(db)
+	schemaHistories, err = executor.getSchemaHistories(context.Background(), db)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -113,31 +119,40 @@ func TestGetSchemaHistories(t *testing.T) {
 }
 
 func TestMySQLShowMigrations(t *testing.T) {
-	var err error
 	executor := NewMySQLMigrateExecutor(mysqlTestSource)
-	err = executor.InitSchemaHistoryTable()
+	err := executor.InitSchemaHistoryTable()
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
 
 	mysqlExecutor := executor.(*mysqlMigrateExecutor)
-	db, err := mysqlExecutor.connectDB()
-	if err != nil {
-		t.Error(err)
-		t.FailNow()
-	}
-	defer db.Close()
+	db := mysqlExecutor.db
+	defer executor.Close()
 	defer func() {
 		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
 	}()
 
 	executor.SetMigrations(mysqlTestMigrations)
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrateInfos) != len(mysqlTestMigrations) || migrateInfos[0].Status != StatusReadyToInstall {
+		t.FailNow()
+	}
+
+	buffer := &bytes.Buffer{}
+	executor.SetOutput(buffer)
 	err = executor.ShowMigrations()
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
+	if !bytes.Contains(buffer.Bytes(), []byte(mysqlTestMigrations[0].Name)) {
+		t.FailNow()
+	}
 }
 
 func TestMySQLInstallMigrations(t *testing.T) {
@@ -151,12 +166,8 @@ func TestMySQLInstallMigrations(t *testing.T) {
 
 	// 连接数据库
 	mysqlExecutor := executor.(*mysqlMigrateExecutor)
-	db, err := mysqlExecutor.connectDB()
-	if err != nil {
-		t.Error(err)
-		t.FailNow()
-	}
-	defer db.Close()
+	db := mysqlExecutor.db
+	defer executor.Close()
 	defer func() {
 		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
 		db.Exec(fmt.Sprintf("DROP TABLE `test_table1`"))
@@ -174,3 +185,1531 @@ func TestMySQLInstallMigrations(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestMySQLInstallMigrationsContextCanceled(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	executor.SetMigrations(mysqlTestMigrations)
+	err := executor.InstallMigrationsContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestMySQLInstallMigrationsContextTimeout(t *testing.T) {
+	// 记录失败之后Migration锁仍由执行的连接持有
+	lockHeld := false
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithCallback(AfterEachMigrateError, func(ctx context.Context, conn *sql.Conn, info *CallbackInfo) error {
+		var holder, self sql.NullInt64
+		err := conn.QueryRowContext(context.Background(), "SELECT IS_USED_LOCK(CONCAT('gomigrate_', SHA1(CONCAT(DATABASE(), '.', ?)))), CONNECTION_ID()",
+			DefaultSchemaHistoryTableName).Scan(&holder, &self)
+		lockHeld = err == nil && holder.Valid && holder.Int64 == self.Int64
+		return nil
+	}))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	migrations := []Migration{{Name: "sleep", Content: "SELECT SLEEP(5)"}}
+	executor.SetMigrations(migrations)
+
+	// 另一个实例等待Migration锁, 取得锁时应看到失败的记录而不是重新执行
+	waiter, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithLockTimeout(10*time.Second))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer waiter.Close()
+	waiter.SetMigrations(migrations)
+	waiterErr := make(chan error, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		waiterErr <- waiter.InstallMigrations()
+	}()
+
+	startTime := time.Now()
+	err = executor.InstallMigrationsContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+		t.FailNow()
+	}
+	if time.Since(startTime) > 3*time.Second {
+		t.Errorf("statement not killed, took %v", time.Since(startTime))
+	}
+	if !lockHeld {
+		t.Error("expect the migration lock to be held after recording the failure")
+	}
+	if err = <-waiterErr; !errors.Is(err, ErrMigrationFailed) {
+		t.Errorf("expect the waiter to see the failed migration, got %v", err)
+	}
+
+	// 正在执行的语句已被KILL QUERY
+	rows, err := db.Query("SHOW PROCESSLIST")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	columns, _ := rows.Columns()
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		for _, value := range values {
+			if strings.Contains(value.String, "SLEEP(5)") {
+				t.Errorf("query still running: %v", values)
+			}
+		}
+	}
+	rows.Close()
+
+	// 失败记录写在新的连接上
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 1 || schemaHistories[0].Success || schemaHistories[0].FailedStatement != 1 {
+		t.Errorf("unexpected schema histories: %+v", schemaHistories)
+	}
+}
+
+func TestOpenMySQLMigrateExecutor(t *testing.T) {
+	_, err := OpenMySQLMigrateExecutor("invalid dsn")
+	if err == nil {
+		t.FailNow()
+	}
+
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithSchemaHistoryTableName("test_schema_history"))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer executor.Close()
+	if executor.GetSchemaHistoryTableName() != "test_schema_history" {
+		t.FailNow()
+	}
+}
+
+func TestNewMySQLMigrateExecutorFromDB(t *testing.T) {
+	db, err := sql.Open("mysql", mysqlTestSource+"&parseTime=true")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer db.Close()
+
+	executor := NewMySQLMigrateExecutorFromDB(db)
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+	}()
+	err = executor.InitSchemaHistoryTable()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	err = executor.Close()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// 外部传入的连接池不应被关闭
+	err = db.Ping()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+func TestNewMySQLMigrateExecutorFromDBWithoutParseTime(t *testing.T) {
+	// loc不是UTC时写入和读取都不应受其影响
+	dsns := []string{mysqlTestSource, mysqlTestSource + "&parseTime=true", mysqlTestSource + "&parseTime=true&loc=Asia%2FShanghai"}
+	for _, dsn := range dsns {
+		for _, opts := range [][]Option{nil, {WithFlywaySchemaHistory()}} {
+			db, err := sql.Open("mysql", dsn)
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			executor := NewMySQLMigrateExecutorFromDB(db, opts...)
+			executor.SetMigrations([]Migration{{Name: "V1__select.sql", Version: "1", Content: "SELECT 1"}})
+			err = executor.InstallMigrations()
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			migrateInfos, err := executor.GetMigrationInfos()
+			db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+			db.Close()
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			// 安装时间按UTC读取, 与DSN是否开启parseTime无关
+			if len(migrateInfos) != 1 || migrateInfos[0].InstalledTime.Location() != time.UTC ||
+				time.Since(migrateInfos[0].InstalledTime) > time.Minute || time.Since(migrateInfos[0].InstalledTime) < -time.Minute {
+				t.Errorf("%s: unexpected migrate infos: %+v", dsn, migrateInfos)
+			}
+		}
+	}
+}
+
+func TestUTCDateTimeScan(t *testing.T) {
+	expected := time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+	values := []interface{}{
+		time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.FixedZone("UTC+8", 8*3600)),
+		[]byte("2024-05-06 07:08:09.123"),
+		"2024-05-06 07:08:09.123000",
+	}
+	for _, value := range values {
+		var scanned utcDateTime
+		if err := scanned.Scan(value); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if !time.Time(scanned).Equal(expected) {
+			t.Errorf("%v: got %v", value, time.Time(scanned))
+		}
+	}
+	var scanned utcDateTime
+	if err := scanned.Scan([]byte("0000-00-00 00:00:00")); err != nil || !time.Time(scanned).IsZero() {
+		t.FailNow()
+	}
+	if err := scanned.Scan(1); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestMySQLRollback(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_table2`")
+		db.Exec("DROP TABLE `test_table3`")
+		db.Exec("DROP TABLE `test_table4`")
+		db.Exec("DROP TABLE `test_table5`")
+	}()
+
+	migrations := make([]Migration, len(mysqlTestMigrations))
+	for i, migration := range mysqlTestMigrations {
+		migration.DownContent = fmt.Sprintf("DROP TABLE `%s`", migration.Name)
+		migrations[i] = migration
+	}
+	executor.SetMigrations(migrations)
+	err := executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	err = executor.Rollback(2)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 3 {
+		t.FailNow()
+	}
+
+	// 缺少回滚脚本时不执行任何回滚
+	migrations[1].DownContent = ""
+	executor.SetMigrations(migrations)
+	err = executor.RollbackTo("test_table1")
+	if !errors.Is(err, ErrDownMigrationMissing) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	migrations[1].DownContent = "DROP TABLE `test_table2`"
+	executor.SetMigrations(migrations)
+	err = executor.RollbackTo("test_table1")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err = mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 1 || schemaHistories[0].Name != "test_table1" {
+		t.FailNow()
+	}
+
+	err = executor.RollbackTo("test_table3")
+	if !errors.Is(err, ErrMigrationNotFound) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	err = executor.RollbackToRank(6)
+	if !errors.Is(err, ErrMigrationNotFound) {
+		t.Error(err)
+		t.FailNow()
+	}
+	err = executor.RollbackToRank(3)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err = mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 3 || schemaHistories[2].Rank != 3 || schemaHistories[2].Name != "test_table3" {
+		t.FailNow()
+	}
+}
+
+func TestMySQLInstallRepeatableMigrations(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+
+	migrations := []Migration{
+		mysqlTestMigrations[0],
+		{
+			Name:       "R__test_table2",
+			Content:    "create table if not exists test_table2(id int unsigned not null auto_increment, primary key(id))",
+			Repeatable: true,
+		},
+	}
+	executor.SetMigrations(migrations)
+	err := executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// 内容变化后重新执行, 并更新原有记录
+	migrations[1].Content = "drop table if exists test_table2"
+	migrations = append(migrations, mysqlTestMigrations[1])
+	executor.SetMigrations(migrations)
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 3 {
+		t.FailNow()
+	}
+	if schemaHistories[1].Type != MigrationTypeRepeatable || schemaHistories[1].Content != migrations[1].Content {
+		t.FailNow()
+	}
+	if schemaHistories[2].Name != mysqlTestMigrations[1].Name {
+		t.FailNow()
+	}
+}
+
+func TestMySQLUpgradeSchemaHistoryTable(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+	}()
+
+	// 旧版本创建的表
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE `%s`(`rank` INT(11) NOT NULL, `name` VARCHAR(156) NOT NULL, `content` TEXT, `installed_time` DATETIME NOT NULL, PRIMARY KEY(`rank`))", executor.GetSchemaHistoryTableName()))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	_, err = db.Exec(fmt.Sprintf("INSERT INTO `%s` VALUES(1, ?, ?, NOW())", executor.GetSchemaHistoryTableName()), mysqlTestMigrations[0].Name, mysqlTestMigrations[0].Content)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// 只读的操作不升级表, 缺失的列使用默认值
+	executor.SetMigrations(mysqlTestMigrations[:1])
+	if _, err = executor.Plan(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrateInfos) != 1 || migrateInfos[0].Status != StatusInstalled || migrateInfos[0].SchemaHistory.Type != MigrationTypeSQL {
+		t.FailNow()
+	}
+	columnTypes, err := mysqlExecutor.getSchemaHistoryColumnTypes(context.Background(), db)
+	if err != nil || len(columnTypes) != 4 {
+		t.Errorf("expect the table not to be upgraded, got %v %v", columnTypes, err)
+		t.FailNow()
+	}
+
+	// 写操作持有锁时升级表
+	if err = executor.InstallMigrations(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	columnTypes, err = mysqlExecutor.getSchemaHistoryColumnTypes(context.Background(), db)
+	if err != nil || len(columnTypes) != len(schemaHistoryColumns) || columnTypes["content"] != "mediumtext" {
+		t.Errorf("expect the table to be upgraded, got %v %v", columnTypes, err)
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 1 || schemaHistories[0].Type != MigrationTypeSQL {
+		t.FailNow()
+	}
+	// 旧记录根据内容补充checksum
+	if schemaHistories[0].Checksum != mysqlTestMigrations[0].GetContentHash() {
+		t.FailNow()
+	}
+}
+
+func TestMySQLPlanUpgradesSchemaHistoryTable(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+
+	// 旧版本创建的表
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE `%s`(`rank` INT(11) NOT NULL, `name` VARCHAR(156) NOT NULL, `content` TEXT, `installed_time` DATETIME NOT NULL, PRIMARY KEY(`rank`))", executor.GetSchemaHistoryTableName()))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	_, err = db.Exec(fmt.Sprintf("INSERT INTO `%s` VALUES(1, ?, ?, NOW())", executor.GetSchemaHistoryTableName()), mysqlTestMigrations[0].Name, mysqlTestMigrations[0].Content)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	executor.SetMigrations(mysqlTestMigrations[:2])
+	plan, err := executor.Plan()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if plan.InitSQL != "" || len(plan.UpgradeSQL) == 0 || len(plan.Migrations) != 1 {
+		t.Errorf("unexpected plan: %+v", plan)
+		t.FailNow()
+	}
+
+	// 按手动执行的方式执行输出的脚本
+	buffer := &bytes.Buffer{}
+	if err = plan.WriteSQL(buffer); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, statement := range splitSQLStatements(buffer.String()) {
+		if _, err = db.Exec(statement.SQL); err != nil {
+			t.Errorf("%s: %v", statement.SQL, err)
+			t.FailNow()
+		}
+	}
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrateInfos) != 2 || migrateInfos[0].Status != StatusInstalled || migrateInfos[1].Status != StatusInstalled ||
+		migrateInfos[0].SchemaHistory.Checksum != mysqlTestMigrations[0].GetContentHash() {
+		t.Errorf("unexpected migrate infos: %+v", migrateInfos)
+	}
+	plan, err = executor.Plan()
+	if err != nil || len(plan.UpgradeSQL) != 0 {
+		t.Errorf("expect the table to be upgraded, got %+v %v", plan, err)
+	}
+}
+
+func TestMySQLSchemaHistoryDetails(t *testing.T) {
+	// DSN中的loc不影响写入的UTC时间
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource+"&loc=Asia%2FShanghai", WithAppVersion("1.2.3"), WithInstalledBy("deployer"))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+	}()
+
+	executor.SetMigrations(mysqlTestMigrations[:1])
+	startTime := time.Now()
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 1 {
+		t.FailNow()
+	}
+	schemaHistory := schemaHistories[0]
+	if schemaHistory.InstalledBy != "deployer" || schemaHistory.AppVersion != "1.2.3" || !schemaHistory.Success || schemaHistory.InstalledHost == "" {
+		t.Errorf("unexpected schema history: %+v", schemaHistory)
+	}
+	if schemaHistory.InstalledTime.Location() != time.UTC || schemaHistory.InstalledTime.Sub(startTime) < -time.Second || time.Since(schemaHistory.InstalledTime) > time.Minute {
+		t.Errorf("unexpected installed time: %s", schemaHistory.InstalledTime)
+	}
+
+	buffer := &bytes.Buffer{}
+	executor.SetOutput(buffer)
+	mysqlExecutor.renderOptions = RenderOptions{Format: OutputCSV, HistoryDetails: true}
+	err = executor.ShowMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !bytes.Contains(buffer.Bytes(), []byte(",deployer,")) || !bytes.Contains(buffer.Bytes(), []byte(",1.2.3,true")) {
+		t.Errorf("unexpected output:\n%s", buffer.String())
+	}
+}
+
+func TestMySQLWithoutSchemaHistoryContent(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithoutSchemaHistoryContent())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+
+	executor.SetMigrations(mysqlTestMigrations[:2])
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var nullContents int
+	err = db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE `content` IS NULL AND `checksum` IS NOT NULL", executor.GetSchemaHistoryTableName())).Scan(&nullContents)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if nullContents != 2 {
+		t.FailNow()
+	}
+	if err = executor.CheckMigrations(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// 只有checksum时也能发现修改
+	modifiedMigrations := []Migration{mysqlTestMigrations[0], mysqlTestMigrations[1]}
+	modifiedMigrations[1].Content += " comment 'modified'"
+	executor.SetMigrations(modifiedMigrations)
+	if err = executor.CheckMigrations(); !errors.Is(err, ErrMigrationModified) {
+		t.FailNow()
+	}
+}
+
+func TestMySQLFlywaySchemaHistory(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithFlywaySchemaHistory())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+	if executor.GetSchemaHistoryTableName() != DefaultFlywaySchemaHistoryTableName {
+		t.FailNow()
+	}
+
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: mysqlTestMigrations[0].Content},
+		{Name: "V1_1__test_table2.sql", Version: "1.1", Content: mysqlTestMigrations[1].Content},
+	}
+	err = executor.InitSchemaHistoryTable()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// 模拟Flyway写入的记录
+	_, err = db.Exec(fmt.Sprintf("INSERT INTO `%s` VALUES(1, '1', 'test table1', 'SQL', 'V1__test_table1.sql', ?, 'flyway', NOW(), 10, 1)", executor.GetSchemaHistoryTableName()), migrations[0].GetFlywayChecksum())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	executor.SetMigrations(migrations)
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	var version, description, script string
+	var checksum int32
+	var success bool
+	err = db.QueryRow(fmt.Sprintf("SELECT `version`, `description`, `script`, `checksum`, `success` FROM `%s` WHERE `installed_rank` = 2", executor.GetSchemaHistoryTableName())).
+		Scan(&version, &description, &script, &checksum, &success)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if version != "1.1" || description != "test table2" || script != migrations[1].Name || checksum != migrations[1].GetFlywayChecksum() || !success {
+		t.FailNow()
+	}
+
+	migrations[0].Content += " comment 'modified'"
+	executor.SetMigrations(migrations)
+	err = executor.CheckMigrations()
+	if !errors.Is(err, ErrMigrationModified) {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+func TestMySQLFlywaySchemaHistoryTakeover(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithFlywaySchemaHistory())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: mysqlTestMigrations[0].Content},
+		{Name: "V1_1__test_table2.sql", Version: "1.1", Content: mysqlTestMigrations[1].Content},
+		{Name: "R__select.sql", Repeatable: true, Content: "SELECT 1"},
+	}
+	removed := Migration{Name: "V0_5__removed.sql", Version: "0.5", Content: "SELECT 0"}
+	err = executor.InitSchemaHistoryTable()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// 模拟Flyway写入的记录: 创建schema的标记, 被repair删除的V0_5, 被撤销的V1_1
+	rows := []struct {
+		version     interface{}
+		description string
+		migrateType string
+		script      string
+		checksum    interface{}
+	}{
+		{nil, "<< Flyway Schema Creation >>", "SCHEMA", "`gomigrate_test`", nil},
+		{"0.5", "removed", "SQL", removed.Name, removed.GetFlywayChecksum()},
+		{"1", "test table1", "SQL", migrations[0].Name, migrations[0].GetFlywayChecksum()},
+		{"1.1", "test table2", "SQL", migrations[1].Name, migrations[1].GetFlywayChecksum()},
+		{"1.1", "test table2", "UNDO_SQL", "U1_1__test_table2.sql", 0},
+		{nil, "select", "SQL", migrations[2].Name, migrations[2].GetFlywayChecksum()},
+		{"0.5", "removed", "DELETE", removed.Name, nil},
+	}
+	for i, row := range rows {
+		_, err = db.Exec(fmt.Sprintf("INSERT INTO `%s` VALUES(?, ?, ?, ?, ?, ?, 'flyway', NOW(), 10, 1)", executor.GetSchemaHistoryTableName()),
+			i+1, row.version, row.description, row.migrateType, row.script, row.checksum)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	executor.SetMigrations(migrations)
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectedStatuses := []MigrateStatus{StatusBaseline, StatusDeleted, StatusInstalled, StatusUndone, StatusUndone, StatusInstalled, StatusDeleted, StatusReadyToInstall}
+	if len(migrateInfos) != len(expectedStatuses) {
+		t.Errorf("unexpected migrate infos: %+v", migrateInfos)
+		t.FailNow()
+	}
+	for i, status := range expectedStatuses {
+		if migrateInfos[i].Status != status {
+			t.Errorf("migrate info %d: expect %s, got %s", i, status, migrateInfos[i].Status)
+		}
+	}
+	if migrateInfos[7].MigrationName != migrations[1].Name {
+		t.FailNow()
+	}
+
+	// 重新执行的可重复执行的Migration与Flyway一样写入新的记录
+	migrations[2].Content = "SELECT 2"
+	executor.SetMigrations(migrations)
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var count int
+	err = db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE `script` = ?", executor.GetSchemaHistoryTableName()), migrations[2].Name).Scan(&count)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if count != 2 {
+		t.Errorf("expect 2 rows of %s, got %d", migrations[2].Name, count)
+	}
+	migrateInfos, err = executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectedStatuses = []MigrateStatus{StatusBaseline, StatusDeleted, StatusInstalled, StatusUndone, StatusUndone, StatusSuperseded, StatusDeleted, StatusInstalled, StatusInstalled}
+	if len(migrateInfos) != len(expectedStatuses) {
+		t.Errorf("unexpected migrate infos: %+v", migrateInfos)
+		t.FailNow()
+	}
+	for i, status := range expectedStatuses {
+		if migrateInfos[i].Status != status {
+			t.Errorf("migrate info %d: expect %s, got %s", i, status, migrateInfos[i].Status)
+		}
+	}
+	if migrateInfos[8].Rank != 9 || migrateInfos[8].MigrationName != migrations[2].Name {
+		t.FailNow()
+	}
+}
+
+func TestMySQLFlywaySchemaHistoryTakeoverWithRankGap(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithFlywaySchemaHistory())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table3`")
+	}()
+
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: mysqlTestMigrations[0].Content},
+		{Name: "V1_1__test_table2.sql", Version: "1.1", Content: mysqlTestMigrations[1].Content},
+		{Name: "V2__test_table3.sql", Version: "2", Content: mysqlTestMigrations[2].Content},
+	}
+	err = executor.InitSchemaHistoryTable()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// flyway repair删除了rank 2的失败记录, 留下空缺
+	for _, rank := range []int{1, 3} {
+		migration := migrations[rank/2]
+		_, err = db.Exec(fmt.Sprintf("INSERT INTO `%s` VALUES(?, ?, ?, 'SQL', ?, ?, 'flyway', NOW(), 10, 1)", executor.GetSchemaHistoryTableName()),
+			rank, migration.Version, migration.GetDescription(), migration.Name, migration.GetFlywayChecksum())
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	executor.SetMigrations(migrations)
+	err = executor.CheckMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	report, err := executor.Repair(RepairOptions{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !report.IsEmpty() {
+		t.Errorf("unexpected repair report: %s", report)
+	}
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectedRanks := []int{1, 3, 4}
+	if len(migrateInfos) != len(expectedRanks) {
+		t.Errorf("unexpected migrate infos: %+v", migrateInfos)
+		t.FailNow()
+	}
+	for i, rank := range expectedRanks {
+		if migrateInfos[i].Rank != rank || migrateInfos[i].Status != StatusInstalled || migrateInfos[i].MigrationName != migrations[i].Name {
+			t.Errorf("migrate info %d: unexpected %+v", i, migrateInfos[i])
+		}
+	}
+}
+
+func TestMySQLBaseline(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table3`")
+	}()
+
+	migrations := []Migration{
+		{Name: "V1__test_table1.sql", Version: "1", Content: mysqlTestMigrations[0].Content},
+		{Name: "V1_1__test_table2.sql", Version: "1.1", Content: mysqlTestMigrations[1].Content},
+		{Name: "V2__test_table3.sql", Version: "2", Content: mysqlTestMigrations[2].Content},
+	}
+	executor.SetMigrations(migrations)
+	err := executor.Baseline("V1_1", "existing database")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	err = executor.Baseline("V1_1", "")
+	if !errors.Is(err, ErrSchemaHistoryNotEmpty) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 2 {
+		t.FailNow()
+	}
+	if schemaHistories[0].Type != MigrationTypeBaseline || schemaHistories[0].Version != "1.1" || schemaHistories[0].Description != "existing database" {
+		t.FailNow()
+	}
+	if schemaHistories[1].Name != migrations[2].Name || schemaHistories[1].Version != "2" {
+		t.FailNow()
+	}
+	err = executor.ShowMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+func TestMySQLRepair(t *testing.T) {
+	executor, db, clear := insertMySQLTestdata(t)
+	defer clear()
+
+	_, err := db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE `rank` = 2", executor.GetSchemaHistoryTableName()))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// 删除第2个Migration后, 后面的Migration顺序前移
+	migrations := append([]Migration{mysqlTestMigrations[0]}, mysqlTestMigrations[2:]...)
+	executor.SetMigrations(migrations)
+	err = executor.CheckMigrations()
+	if !errors.Is(err, ErrBrokenSchemaHistory) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	report, err := executor.Repair(RepairOptions{DryRun: true})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(report.Renumbered) != 3 {
+		t.FailNow()
+	}
+	err = executor.CheckMigrations()
+	if !errors.Is(err, ErrBrokenSchemaHistory) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	_, err = executor.Repair(RepairOptions{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	err = executor.CheckMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	migrations[0].Content += " comment 'modified'"
+	executor.SetMigrations(migrations)
+	_, err = executor.Repair(RepairOptions{AcceptModified: []string{migrations[0].Name}})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	err = executor.CheckMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+func TestInterpolateMySQLQuery(t *testing.T) {
+	query, err := interpolateMySQLQuery("INSERT INTO `t` VALUES(?, ?, ?, ?, ?)", []interface{}{
+		1, "it's\n\\", sql.NullString{}, true, time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if query != "INSERT INTO `t` VALUES(1, 'it\\'s\\n\\\\', NULL, 1, NOW())" {
+		t.Error(query)
+	}
+	_, err = interpolateMySQLQuery("SELECT ?", nil)
+	if err == nil {
+		t.FailNow()
+	}
+}
+
+func TestMySQLPlan(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+
+	executor.SetMigrations(mysqlTestMigrations[:2])
+	plan, err := executor.Plan()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if plan.InitSQL == "" || len(plan.Migrations) != 2 || plan.Migrations[1].Rank != 2 {
+		t.FailNow()
+	}
+	if plan.Migrations[0].ContentHash != mysqlTestMigrations[0].GetContentHash() {
+		t.FailNow()
+	}
+
+	// 手动执行脚本后, 不再有待安装的Migration
+	script := &bytes.Buffer{}
+	err = plan.WriteSQL(script)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, statement := range splitSQLStatements(script.String()) {
+		_, err = db.Exec(statement.SQL)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	plan, err = executor.Plan()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if plan.InitSQL != "" || len(plan.Migrations) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestMySQLInstallMigrationsConcurrently(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		for _, migration := range mysqlTestMigrations {
+			db.Exec(fmt.Sprintf("DROP TABLE `%s`", migration.Name))
+		}
+	}()
+
+	// 多个执行器同时安装, 后获得锁的执行器发现没有需要安装的Migration
+	errs := make(chan error, 3)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			executor, err := OpenMySQLMigrateExecutor(mysqlTestSource)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer executor.Close()
+			executor.SetMigrations(mysqlTestMigrations)
+			errs <- executor.InstallMigrations()
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != len(mysqlTestMigrations) {
+		t.FailNow()
+	}
+}
+
+func TestMySQLLockTimeout(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithLockTimeout(time.Second))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+	}()
+
+	holder := NewMySQLMigrateExecutor(mysqlTestSource).(*mysqlMigrateExecutor)
+	defer holder.Close()
+	conn, release, err := holder.connectDB(context.Background())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer release()
+	unlock, err := holder.lock(context.Background(), conn)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	executor.SetMigrations(mysqlTestMigrations[:1])
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("expect ErrLockTimeout, got %v", err)
+	}
+	unlock()
+	if err = executor.InstallMigrations(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+func TestMySQLResumeFailedMigration(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_fail1`")
+		db.Exec("DROP TABLE `test_fail2`")
+		db.Exec("DROP TABLE `test_fail3`")
+	}()
+
+	migrations := []Migration{
+		mysqlTestMigrations[0],
+		{
+			Name: "test_fail",
+			Content: "create table test_fail1(id int primary key);\n" +
+				"insert into test_fail1 values(1);\n" +
+				"insert into test_fail_missing values(1);\n" +
+				"create table test_fail2(data varchar(20) default 'a;b')",
+		},
+	}
+	executor.SetMigrations(migrations)
+	err := executor.InstallMigrations()
+	var migrationErr *MigrationError
+	if !errors.Is(err, ErrMigrationFailed) || !errors.As(err, &migrationErr) || migrationErr.Statement != 3 ||
+		migrationErr.Line != 3 || migrationErr.SQL != "insert into test_fail_missing values(1)" {
+		t.Errorf("expect statement 3 failed, got %v", err)
+		t.FailNow()
+	}
+
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrateInfos) != 2 || migrateInfos[1].Status != StatusFailed || migrateInfos[1].SchemaHistory.FailedStatement != 3 {
+		t.FailNow()
+	}
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrMigrationFailed) {
+		t.Errorf("expect ErrMigrationFailed, got %v", err)
+	}
+
+	// 修改失败的语句后从该语句继续执行, 之前的语句不会重复执行
+	migrations[1].Content = strings.Replace(migrations[1].Content, "insert into test_fail_missing values(1)", "create table test_fail3(id int)", 1)
+	executor.SetMigrations(migrations)
+	if err = executor.ResumeFailedMigration(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = executor.CheckMigrations(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err = db.Exec("INSERT INTO `test_fail2` VALUES(DEFAULT)"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = executor.ResumeFailedMigration(); !errors.Is(err, ErrNoFailedMigration) {
+		t.Errorf("expect ErrNoFailedMigration, got %v", err)
+	}
+}
+
+func TestMySQLResumeFailedMigrationWithFlywaySchemaHistory(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithFlywaySchemaHistory())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	db := executor.(*mysqlMigrateExecutor).db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_fail1`")
+		db.Exec("DROP TABLE `test_fail2`")
+	}()
+
+	migrations := []Migration{{
+		Name:    "V1__test_fail.sql",
+		Version: "1",
+		Content: "create table test_fail1(id int);\ninsert into test_fail_missing values(1)",
+	}}
+	executor.SetMigrations(migrations)
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrMigrationFailed) {
+		t.Errorf("expect ErrMigrationFailed, got %v", err)
+		t.FailNow()
+	}
+
+	// Flyway格式的记录没有失败语句的序号, 不能继续执行, 只能标记为已修复
+	migrations[0].Content = "create table test_fail1(id int);\ncreate table test_fail2(id int)"
+	executor.SetMigrations(migrations)
+	if err = executor.ResumeFailedMigration(); !errors.Is(err, ErrFailedStatementUnknown) {
+		t.Errorf("expect ErrFailedStatementUnknown, got %v", err)
+		t.FailNow()
+	}
+	if _, err = db.Exec("create table test_fail2(id int)"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = executor.MarkFailedMigrationFixed(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = executor.CheckMigrations(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMySQLMarkFailedMigrationFixed(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+	}()
+
+	executor.SetMigrations([]Migration{{Name: "test_fail", Content: "insert into test_fail_missing values(1)"}})
+	if err := executor.InstallMigrations(); !errors.Is(err, ErrMigrationFailed) {
+		t.Errorf("expect ErrMigrationFailed, got %v", err)
+		t.FailNow()
+	}
+	if err := executor.MarkFailedMigrationFixed(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrateInfos) != 1 || migrateInfos[0].Status != StatusInstalled || migrateInfos[0].SchemaHistory.FailedStatement != 0 {
+		t.FailNow()
+	}
+}
+
+func TestMySQLInstallMigrationsTo(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		for _, migration := range mysqlTestMigrations {
+			db.Exec(fmt.Sprintf("DROP TABLE `%s`", migration.Name))
+		}
+	}()
+
+	executor.SetMigrations(mysqlTestMigrations)
+	err := executor.InstallMigrationsTo("test_table2")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = executor.InstallMigrationsTo("test_table9"); !errors.Is(err, ErrMigrationNotFound) {
+		t.FailNow()
+	}
+
+	mysqlExecutor.target = "test_table3"
+	migrateInfos, err := executor.GetMigrationInfos()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	statuses := []MigrateStatus{StatusInstalled, StatusInstalled, StatusReadyToInstall, StatusAboveTarget, StatusAboveTarget}
+	for i, status := range statuses {
+		if migrateInfos[i].Status != status {
+			t.Errorf("migrate info %d: expect status %s, got %s", i, status, migrateInfos[i].Status)
+		}
+	}
+	buffer := &bytes.Buffer{}
+	executor.SetOutput(buffer)
+	if err = executor.ShowMigrations(); err != nil || !strings.Contains(buffer.String(), "ABOVE TARGET") {
+		t.FailNow()
+	}
+}
+
+func TestMySQLInstallMigrationsOutOfOrder(t *testing.T) {
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithOutOfOrder())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		for _, migration := range mysqlTestMigrations {
+			db.Exec(fmt.Sprintf("DROP TABLE `%s`", migration.Name))
+		}
+	}()
+
+	executor.SetMigrations([]Migration{mysqlTestMigrations[0], mysqlTestMigrations[2]})
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// 插入到已安装的Migration之间, 按实际安装顺序记录
+	executor.SetMigrations(mysqlTestMigrations[:3])
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	names := []string{"test_table1", "test_table3", "test_table2"}
+	if len(schemaHistories) != len(names) {
+		t.FailNow()
+	}
+	for i, name := range names {
+		if schemaHistories[i].Name != name || schemaHistories[i].Rank != i+1 {
+			t.Errorf("expect %s at rank %d, got %s at rank %d", name, i+1, schemaHistories[i].Name, schemaHistories[i].Rank)
+		}
+	}
+
+	// 不允许乱序时, 插入的Migration导致MIGRATION MODIFIED
+	mysqlExecutor.outOfOrder = false
+	if err = executor.CheckMigrations(); !errors.Is(err, ErrMigrationModified) {
+		t.Errorf("expect ErrMigrationModified, got %v", err)
+	}
+}
+
+func TestMySQLFuncMigrations(t *testing.T) {
+	executor := NewMySQLMigrateExecutor(mysqlTestSource)
+	mysqlExecutor := executor.(*mysqlMigrateExecutor)
+	db := mysqlExecutor.db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+	}()
+
+	migrations := []Migration{
+		mysqlTestMigrations[0],
+		{
+			Name: "seed_test_table1",
+			UpFunc: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "insert into test_table1(data) values('seed')")
+				return err
+			},
+			DownFunc: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "delete from test_table1 where data = 'seed'")
+				return err
+			},
+		},
+	}
+	executor.SetMigrations(migrations)
+	if err := executor.InstallMigrations(); !errors.Is(err, ErrInvalidMigrations) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	migrations[1].FuncChecksum = "v1"
+	executor.SetMigrations(migrations)
+	plan, err := executor.Plan()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = plan.WriteSQL(&bytes.Buffer{}); !errors.Is(err, ErrFuncMigrationNotInSQL) {
+		t.FailNow()
+	}
+	err = executor.InstallMigrations()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var count int
+	if err = db.QueryRow("select count(*) from test_table1").Scan(&count); err != nil || count != 1 {
+		t.FailNow()
+	}
+	schemaHistories, err := mysqlExecutor.getSchemaHistories(context.Background(), db)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(schemaHistories) != 2 || schemaHistories[1].Type != MigrationTypeFunc || schemaHistories[1].Checksum != migrations[1].GetContentHash() {
+		t.FailNow()
+	}
+
+	// 修改校验值视为修改了Migration
+	migrations[1].FuncChecksum = "v2"
+	executor.SetMigrations(migrations)
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrMigrationModified) {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	migrations[1].FuncChecksum = "v1"
+	executor.SetMigrations(migrations)
+	if err = executor.Rollback(1); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = db.QueryRow("select count(*) from test_table1").Scan(&count); err != nil || count != 0 {
+		t.FailNow()
+	}
+
+	// 函数返回错误时回滚事务并记录失败
+	migrations[1].UpFunc = func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "insert into test_table1(data) values('seed')"); err != nil {
+			return err
+		}
+		return errors.New("seed failed")
+	}
+	executor.SetMigrations(migrations)
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrMigrationFailed) {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err = db.QueryRow("select count(*) from test_table1").Scan(&count); err != nil || count != 0 {
+		t.FailNow()
+	}
+}
+
+func TestMySQLCallbacks(t *testing.T) {
+	scripts, err := GetCallbacksFromFlywayEmbedFS(testdataFS, "testdata/embed_flyway/callbacks")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	events := make([]string, 0)
+	record := func(ctx context.Context, conn *sql.Conn, info *CallbackInfo) error {
+		event := string(info.Event)
+		if info.Migration != nil {
+			event += " " + info.Migration.Name
+		}
+		events = append(events, event)
+		return nil
+	}
+	options := []Option{WithCallbackScripts(scripts)}
+	for _, event := range []CallbackEvent{BeforeMigrate, BeforeEachMigrate, AfterEachMigrate, AfterEachMigrateError, AfterMigrate, AfterMigrateError} {
+		options = append(options, WithCallback(event, record))
+	}
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, options...)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	db := executor.(*mysqlMigrateExecutor).db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_callback`")
+		db.Exec("DROP TABLE `test_table1`")
+		db.Exec("DROP TABLE `test_table2`")
+	}()
+
+	executor.SetMigrations(mysqlTestMigrations[:2])
+	if err = executor.InstallMigrations(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectedEvents := []string{
+		"beforeMigrate",
+		"beforeEachMigrate test_table1", "afterEachMigrate test_table1",
+		"beforeEachMigrate test_table2", "afterEachMigrate test_table2",
+		"afterMigrate",
+	}
+	if strings.Join(events, ", ") != strings.Join(expectedEvents, ", ") {
+		t.Errorf("expect events %v, got %v", expectedEvents, events)
+		t.FailNow()
+	}
+	var count int
+	if err = db.QueryRow("select count(*) from test_callback").Scan(&count); err != nil || count != 2 {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	events = events[:0]
+	executor.SetMigrations(append(mysqlTestMigrations[:2:2], Migration{Name: "test_invalid", Content: "invalid sql"}))
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrMigrationFailed) {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectedEvents = []string{"beforeMigrate", "beforeEachMigrate test_invalid", "afterEachMigrateError test_invalid", "afterMigrateError"}
+	if strings.Join(events, ", ") != strings.Join(expectedEvents, ", ") {
+		t.Errorf("expect events %v, got %v", expectedEvents, events)
+	}
+}
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.messages = append(l.messages, msg)
+}
+func (l *recordingLogger) Info(msg string, args ...interface{}) { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Warn(msg string, args ...interface{}) { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) {
+	l.messages = append(l.messages, msg)
+}
+
+func TestMySQLLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	executor, err := OpenMySQLMigrateExecutor(mysqlTestSource, WithLogger(logger))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	db := executor.(*mysqlMigrateExecutor).db
+	defer executor.Close()
+	defer func() {
+		db.Exec(fmt.Sprintf("DROP TABLE `%s`", executor.GetSchemaHistoryTableName()))
+		db.Exec("DROP TABLE `test_table1`")
+	}()
+
+	executor.SetMigrations(mysqlTestMigrations[:1])
+	if err = executor.InstallMigrations(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := "acquiring migration lock, migration lock acquired, installing migration, schema history written, installed migration, migration lock released"
+	if strings.Join(logger.messages, ", ") != expected {
+		t.Errorf("expect %s, got %v", expected, logger.messages)
+	}
+
+	logger.messages = nil
+	executor.SetMigrations(nil)
+	if err = executor.InstallMigrations(); !errors.Is(err, ErrMigrationMissing) {
+		t.FailNow()
+	}
+	if len(logger.messages) < 3 || logger.messages[2] != "migration check failed" {
+		t.Errorf("expect check failure to be logged, got %v", logger.messages)
+	}
+}
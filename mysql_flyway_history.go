@@ -0,0 +1,117 @@
+package gomigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (m *mysqlMigrateExecutor) createFlywaySchemaHistoryTableSQL() string {
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`(", m.GetSchemaHistoryTableName()),
+		"`installed_rank` INT NOT NULL,",
+		"`version` VARCHAR(50),",
+		"`description` VARCHAR(200) NOT NULL,",
+		"`type` VARCHAR(20) NOT NULL,",
+		"`script` VARCHAR(1000) NOT NULL,",
+		"`checksum` INT,",
+		"`installed_by` VARCHAR(100) NOT NULL,",
+		"`installed_on` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,",
+		"`execution_time` INT NOT NULL,",
+		"`success` BOOL NOT NULL,",
+		"PRIMARY KEY(`installed_rank`),",
+		fmt.Sprintf("KEY `%s_s_idx`(`success`)", m.GetSchemaHistoryTableName()),
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;",
+	}, "\n")
+}
+
+func (m *mysqlMigrateExecutor) getFlywaySchemaHistories(ctx context.Context, db dbConn) ([]SchemaHistory, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT `installed_rank`, `version`, `description`, `type`, `script`, `checksum`, `installed_by`, `installed_on`, `execution_time`, `success` FROM `%s` ORDER BY `installed_rank` ASC", m.GetSchemaHistoryTableName()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemaHistories := make([]SchemaHistory, 0)
+	for rows.Next() {
+		schemaHistory := SchemaHistory{}
+		var version sql.NullString
+		var checksum sql.NullInt64
+		var installedTime utcDateTime
+		var executionTime int64
+		err = rows.Scan(&schemaHistory.Rank, &version, &schemaHistory.Description, &schemaHistory.Type, &schemaHistory.Name,
+			&checksum, &schemaHistory.InstalledBy, &installedTime, &executionTime, &schemaHistory.Success)
+		if err != nil {
+			return nil, err
+		}
+		schemaHistory.Version = version.String
+		// Flyway中version为空的SQL记录是可重复执行的Migration
+		schemaHistory.Repeatable = !version.Valid && (schemaHistory.Type == MigrationTypeSQL || schemaHistory.Type == MigrationTypeJDBC)
+		if checksum.Valid {
+			schemaHistory.Checksum = strconv.FormatInt(checksum.Int64, 10)
+		}
+		schemaHistory.InstalledTime = time.Time(installedTime)
+		schemaHistory.ExecutionTime = time.Duration(executionTime) * time.Millisecond
+		schemaHistories = append(schemaHistories, schemaHistory)
+	}
+
+	return schemaHistories, rows.Err()
+}
+
+func (m *mysqlMigrateExecutor) addFlywaySchemaHistoryQuery(schemaHistory *SchemaHistory) (string, []interface{}) {
+	var version interface{}
+	if !schemaHistory.Repeatable {
+		version = schemaHistory.Version
+		if schemaHistory.Version == "" {
+			version = strconv.Itoa(schemaHistory.Rank)
+		}
+	}
+	migrationType := schemaHistory.Type
+	if migrationType == "" {
+		migrationType = MigrationTypeSQL
+		if schemaHistory.IsFunc() {
+			migrationType = MigrationTypeJDBC
+		}
+	}
+	var checksum interface{}
+	if migrationType != MigrationTypeBaseline {
+		checksum = schemaHistory.GetFlywayChecksum()
+	}
+	return fmt.Sprintf("INSERT INTO `%s`(`installed_rank`, `version`, `description`, `type`, `script`, `checksum`, `installed_by`, `installed_on`, `execution_time`, `success`) VALUES(?, ?, ?, ?, ?, ?, COALESCE(?, SUBSTRING_INDEX(CURRENT_USER(), '@', 1)), ?, ?, ?)", m.GetSchemaHistoryTableName()),
+		[]interface{}{
+			schemaHistory.Rank,
+			version,
+			schemaHistory.getDescription(),
+			migrationType,
+			schemaHistory.Name,
+			checksum,
+			m.installedByArg(),
+			utcDateTime(schemaHistory.InstalledTime),
+			schemaHistory.ExecutionTime.Milliseconds(),
+			schemaHistory.Success,
+		}
+}
+
+func (m *mysqlMigrateExecutor) updateFlywaySchemaHistoryQuery(schemaHistory *SchemaHistory) (string, []interface{}) {
+	return fmt.Sprintf("UPDATE `%s` SET `checksum` = ?, `installed_on` = ?, `execution_time` = ?, `success` = ? WHERE `installed_rank` = ?", m.GetSchemaHistoryTableName()),
+		[]interface{}{
+			schemaHistory.GetFlywayChecksum(),
+			utcDateTime(schemaHistory.InstalledTime),
+			schemaHistory.ExecutionTime.Milliseconds(),
+			schemaHistory.Success,
+			schemaHistory.Rank,
+		}
+}
+
+func (m *mysqlMigrateExecutor) realignFlywaySchemaHistory(ctx context.Context, db dbConn, rank int, migration *Migration) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET `script` = ?, `description` = ?, `checksum` = ? WHERE `installed_rank` = ?", m.GetSchemaHistoryTableName()),
+		migration.Name,
+		migration.GetDescription(),
+		migration.GetFlywayChecksum(),
+		rank,
+	)
+	return err
+}
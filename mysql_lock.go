@@ -0,0 +1,39 @@
+package gomigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// lock 获取MySQL命名锁, 同一数据库中使用同一张Schema History表的执行器互斥.
+// 命名锁属于连接, db必须是connectDB返回的独占连接
+func (m *mysqlMigrateExecutor) lock(ctx context.Context, db dbConn) (unlock func(), err error) {
+	// 锁名最长64个字符, 使用数据库名和表名的哈希
+	var lockName string
+	err = db.QueryRowContext(ctx, "SELECT CONCAT('gomigrate_', SHA1(CONCAT(IFNULL(DATABASE(), ''), '.', ?)))", m.GetSchemaHistoryTableName()).Scan(&lockName)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := m.getLockTimeout()
+	m.getLogger().Debug("acquiring migration lock", "lock", lockName, "timeout", timeout)
+	var acquired sql.NullInt64
+	err = db.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int64(math.Ceil(timeout.Seconds()))).Scan(&acquired)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		m.getLogger().Error("migration lock timeout", "lock", lockName, "timeout", timeout)
+		return nil, fmt.Errorf("%w: %s after %s", ErrLockTimeout, m.GetSchemaHistoryTableName(), timeout)
+	}
+	m.getLogger().Info("migration lock acquired", "lock", lockName, "table", m.GetSchemaHistoryTableName())
+
+	return func() {
+		// ctx可能已经取消, 释放锁不能依赖ctx
+		var released sql.NullInt64
+		db.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName).Scan(&released)
+		m.getLogger().Debug("migration lock released", "lock", lockName)
+	}, nil
+}
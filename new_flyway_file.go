@@ -0,0 +1,127 @@
+package gomigrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionBump 决定新Migration的版本号如何从已有的最大版本号计算
+type VersionBump string
+
+const (
+	// BumpMajor 主版本号加1, 如3_2之后为4
+	BumpMajor VersionBump = "major"
+	// BumpMinor 次版本号加1, 如3_2之后为3_3, 3之后为3_1
+	BumpMinor VersionBump = "minor"
+	// BumpTimestamp 使用UTC时间戳, 如20210102150405, 多人并行开发时不易冲突
+	BumpTimestamp VersionBump = "timestamp"
+)
+
+var reUnsafeDescription = regexp.MustCompile("[^A-Za-z0-9]+")
+
+// NewFlywayMigrationFile 在dir中创建下一个版本的空Migration文件, 返回文件路径.
+// 已有的版本化和回滚文件都参与计算最大版本号, 描述中的空格和符号替换为下划线
+func NewFlywayMigrationFile(dir string, description string, bump VersionBump) (string, error) {
+	safeDescription := strings.Trim(reUnsafeDescription.ReplaceAllString(description, "_"), "_")
+	if safeDescription == "" {
+		return "", fmt.Errorf("%w: empty description %q", ErrInvalidMigrations, description)
+	}
+	latestVersion, err := latestFlywayVersion(dir)
+	if err != nil {
+		return "", err
+	}
+	version, err := nextMigrationVersion(latestVersion, bump, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	filename := fmt.Sprintf("V%s__%s.sql", strings.ReplaceAll(version.String(), ".", "_"), safeDescription)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return createNewFile(filepath.Join(dir, filename), "")
+}
+
+// NewFlywayUndoFile 为NewFlywayMigrationFile创建的Migration创建回滚文件U<version>__<description>.sql
+func NewFlywayUndoFile(migrationPath string) (string, error) {
+	file, err := parseFlywayFilename(filepath.Base(migrationPath))
+	if err != nil {
+		return "", err
+	}
+	if file == nil || file.Prefix != "V" {
+		return "", fmt.Errorf("%w: %s is not a versioned migration", ErrInvalidMigrations, migrationPath)
+	}
+	undoFilename := "U" + strings.TrimPrefix(strings.TrimPrefix(filepath.Base(migrationPath), "V"), "v")
+	return createNewFile(filepath.Join(filepath.Dir(migrationPath), undoFilename), fmt.Sprintf("-- undo %s\n", filepath.Base(migrationPath)))
+}
+
+// latestFlywayVersion 返回dir中版本化和回滚文件的最大版本号, 没有时为空
+func latestFlywayVersion(dir string) (MigrationVersion, error) {
+	var latestVersion MigrationVersion
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// 无效的文件名不是Migration, 不参与计算
+		file, parseErr := parseFlywayFilename(d.Name())
+		if parseErr != nil || file == nil || file.Prefix == "R" {
+			return nil
+		}
+		if latestVersion == nil || file.Version.Compare(latestVersion) > 0 {
+			latestVersion = file.Version
+		}
+		return nil
+	})
+	return latestVersion, err
+}
+
+func nextMigrationVersion(latestVersion MigrationVersion, bump VersionBump, now time.Time) (MigrationVersion, error) {
+	switch bump {
+	case BumpMajor:
+		if len(latestVersion) == 0 {
+			return MigrationVersion{1}, nil
+		}
+		return MigrationVersion{latestVersion[0] + 1}, nil
+	case BumpMinor:
+		if len(latestVersion) == 0 {
+			return MigrationVersion{1}, nil
+		}
+		if len(latestVersion) == 1 {
+			return MigrationVersion{latestVersion[0], 1}, nil
+		}
+		return MigrationVersion{latestVersion[0], latestVersion[1] + 1}, nil
+	case BumpTimestamp:
+		timestamp, _ := strconv.Atoi(now.UTC().Format("20060102150405"))
+		version := MigrationVersion{timestamp}
+		if version.Compare(latestVersion) <= 0 {
+			return nil, fmt.Errorf("%w: timestamp %d is not after version %s", ErrInvalidMigrations, timestamp, latestVersion)
+		}
+		return version, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownVersionBump, bump)
+}
+
+// createNewFile 创建文件, 文件已存在时返回错误而不是覆盖
+func createNewFile(path string, content string) (string, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.WriteString(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
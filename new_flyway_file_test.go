@@ -0,0 +1,89 @@
+package gomigrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNextMigrationVersion(t *testing.T) {
+	now := time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC)
+	cases := []struct {
+		Latest   MigrationVersion
+		Bump     VersionBump
+		Expected string
+	}{
+		{nil, BumpMajor, "1"},
+		{MigrationVersion{3, 2}, BumpMajor, "4"},
+		{nil, BumpMinor, "1"},
+		{MigrationVersion{3}, BumpMinor, "3.1"},
+		{MigrationVersion{3, 2, 1}, BumpMinor, "3.3"},
+		{MigrationVersion{3, 2}, BumpTimestamp, "20210102150405"},
+	}
+	for _, c := range cases {
+		version, err := nextMigrationVersion(c.Latest, c.Bump, now)
+		if err != nil || version.String() != c.Expected {
+			t.Errorf("expect %s after %s by %s, got %s %v", c.Expected, c.Latest, c.Bump, version, err)
+		}
+	}
+	if _, err := nextMigrationVersion(MigrationVersion{20300101000000}, BumpTimestamp, now); !errors.Is(err, ErrInvalidMigrations) {
+		t.FailNow()
+	}
+	if _, err := nextMigrationVersion(nil, "patch", now); !errors.Is(err, ErrUnknownVersionBump) {
+		t.FailNow()
+	}
+}
+
+func TestNewFlywayMigrationFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomigrate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	for _, filename := range []string{"V1__test_table1.sql", "sub/V3_1__test_table2.sql", "U4__test_table3.sql", "R__test_view1.sql"} {
+		path := filepath.Join(dir, filename)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = ioutil.WriteFile(path, nil, 0644)
+		}
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	path, err := NewFlywayMigrationFile(dir, " add users' table ", BumpMinor)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if path != filepath.Join(dir, "V4_1__add_users_table.sql") {
+		t.Errorf("unexpected migration file %s", path)
+	}
+	undoPath, err := NewFlywayUndoFile(path)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if undoPath != filepath.Join(dir, "U4_1__add_users_table.sql") {
+		t.Errorf("unexpected undo file %s", undoPath)
+	}
+	if _, err = NewFlywayUndoFile(path); !os.IsExist(err) {
+		t.FailNow()
+	}
+
+	migrations, err := GetMigrationsFromFlywayDir(dir)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrations) != 4 || migrations[2].Name != "V4_1__add_users_table.sql" || migrations[2].DownContent == "" {
+		t.Errorf("unexpected migrations %v", migrations)
+	}
+	if _, err = NewFlywayMigrationFile(dir, "!!", BumpMajor); !errors.Is(err, ErrInvalidMigrations) {
+		t.FailNow()
+	}
+}
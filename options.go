@@ -0,0 +1,120 @@
+package gomigrate
+
+import (
+	"io"
+	"time"
+)
+
+type Option func(b *BaseExecutor)
+
+func WithSchemaHistoryTableName(tableName string) Option {
+	return func(b *BaseExecutor) {
+		b.tableName = tableName
+	}
+}
+
+// WithFlywaySchemaHistory 使用Flyway格式的Schema History表, 默认表名为flyway_schema_history,
+// 可以直接接管Flyway管理的数据库, Flyway也能读取gomigrate写入的记录
+func WithFlywaySchemaHistory() Option {
+	return func(b *BaseExecutor) {
+		b.flywayHistory = true
+	}
+}
+
+// WithoutSchemaHistoryContent 不在Schema History中保存Migration的完整内容, 只保存checksum,
+// 适用于很大的Migration, 如超过MEDIUMTEXT列16MB上限或max_allowed_packet的Migration
+func WithoutSchemaHistoryContent() Option {
+	return func(b *BaseExecutor) {
+		b.withoutContent = true
+	}
+}
+
+// WithOutput 设置ShowMigrations和Repair的输出位置, 默认为os.Stdout
+func WithOutput(w io.Writer) Option {
+	return func(b *BaseExecutor) {
+		b.output = w
+	}
+}
+
+// WithOutputFormat 设置ShowMigrations的输出格式, 默认为OutputTable
+func WithOutputFormat(format OutputFormat) Option {
+	return func(b *BaseExecutor) {
+		b.renderOptions.Format = format
+	}
+}
+
+// WithHistoryDetails ShowMigrations同时输出执行耗时, 执行者, 应用版本和是否成功
+func WithHistoryDetails() Option {
+	return func(b *BaseExecutor) {
+		b.renderOptions.HistoryDetails = true
+	}
+}
+
+// WithInstalledBy 指定Schema History中记录的执行者, 默认为当前数据库用户
+func WithInstalledBy(installedBy string) Option {
+	return func(b *BaseExecutor) {
+		b.installedBy = installedBy
+	}
+}
+
+// WithAppVersion 指定Schema History中记录的应用版本, 如版本号或构建号
+func WithAppVersion(appVersion string) Option {
+	return func(b *BaseExecutor) {
+		b.appVersion = appVersion
+	}
+}
+
+// WithLockTimeout 设置等待其他执行器释放锁的时间, 默认为DefaultLockTimeout.
+// 安装, 回滚, 基线和修复都会先获取锁, 等到锁后重新读取Schema History
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(b *BaseExecutor) {
+		b.lockTimeout = timeout
+	}
+}
+
+// WithTarget 只安装到target为止, target为Migration名称或版本号, ShowMigrations将之后的Migration显示为ABOVE TARGET
+func WithTarget(target string) Option {
+	return func(b *BaseExecutor) {
+		b.target = target
+	}
+}
+
+// WithOutOfOrder 允许安装低于已安装版本的Migration, Schema History按名称或版本号对应Migration,
+// rank记录实际的安装顺序
+func WithOutOfOrder() Option {
+	return func(b *BaseExecutor) {
+		b.outOfOrder = true
+	}
+}
+
+func (b *BaseExecutor) applyOptions(opts []Option) {
+	for _, opt := range opts {
+		opt(b)
+	}
+}
+
+// WithCallback 注册事件的回调, 同一事件可以注册多个, 按注册顺序执行
+func WithCallback(event CallbackEvent, callback Callback) Option {
+	return func(b *BaseExecutor) {
+		if b.callbacks == nil {
+			b.callbacks = make(map[CallbackEvent][]Callback)
+		}
+		b.callbacks[event] = append(b.callbacks[event], callback)
+	}
+}
+
+// WithCallbackScripts 注册回调SQL文件, 如GetCallbacksFromFlywayDir的返回值
+func WithCallbackScripts(scripts []CallbackScript) Option {
+	return func(b *BaseExecutor) {
+		for _, script := range scripts {
+			WithCallback(script.Event, script.callback())(b)
+		}
+	}
+}
+
+// WithLogger 记录加锁, 每个Migration的开始和结束及耗时, Schema History的写入和检查失败等步骤, 默认不记录
+func WithLogger(logger Logger) Option {
+	return func(b *BaseExecutor) {
+		b.logger = logger
+	}
+}
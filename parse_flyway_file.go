@@ -2,105 +2,278 @@ package gomigrate
 
 import (
 	"embed"
+	"fmt"
 	"io/fs"
-	"io/ioutil"
-	"path/filepath"
+	"os"
+	"path"
 	"regexp"
 	"sort"
+	"strings"
 )
 
-var reValidFlywayFilename = regexp.MustCompile("(?i)^v(\\d+(_\\d+)*)__(.+)\\.sql$")
+var reValidFlywayFilename = regexp.MustCompile("(?i)^([vur])(\\d+(_\\d+)*)?__(.+)\\.sql$")
 
-func GetMigrationsFromFlywayDir(sourcePath string) ([]Migration, error) {
-	sortableMigrations := make(SortableMigrations, 0)
-	err := filepath.WalkDir(sourcePath, func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-		baseFilename := filepath.Base(path)
-		matches := reValidFlywayFilename.FindStringSubmatch(baseFilename)
-		if len(matches) == 0 {
-			return nil
-		}
+// reFlywayLookAlike 匹配看起来像Migration但文件名无效的SQL文件, 如4_1__x.sql, v4.2__x.sql, V5_x.sql
+var reFlywayLookAlike = regexp.MustCompile("(?i)^([vur]?\\d|[vur]_|.*__).*\\.sql$")
 
-		versionStr := matches[1]
-		migrationVersion, err := ParseMigrationVersion(versionStr)
-		if err != nil {
-			return err
-		}
+type flywayFile struct {
+	Prefix      string
+	Version     MigrationVersion
+	Name        string
+	Description string
+}
 
-		content, err := ioutil.ReadFile(path)
-		if err != nil {
-			return err
+// parseFlywayFilename 解析V/U开头的版本化文件名和R开头的可重复执行文件名,
+// 不是Migration文件时返回空, 符合格式但缺少或多余版本号, 或版本号无法解析时返回错误
+func parseFlywayFilename(filename string) (*flywayFile, error) {
+	matches := reValidFlywayFilename.FindStringSubmatch(filename)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	file := &flywayFile{
+		Prefix:      strings.ToUpper(matches[1]),
+		Name:        matches[0],
+		Description: matches[4],
+	}
+	versionStr := matches[2]
+	if file.Prefix == "R" {
+		if versionStr != "" {
+			return nil, fmt.Errorf("repeatable migration %s must not have a version", filename)
 		}
+		return file, nil
+	}
+	if versionStr == "" {
+		return nil, fmt.Errorf("migration %s has no version", filename)
+	}
+	migrationVersion, err := ParseMigrationVersion(versionStr)
+	if err != nil {
+		return nil, fmt.Errorf("migration %s has invalid version %s: %w", filename, versionStr, err)
+	}
+	file.Version = migrationVersion
+	return file, nil
+}
 
-		migration := &Migration{
-			Name:    matches[0],
-			Content: string(content),
-		}
-		sortableMigrations = append(sortableMigrations, &SortableMigration{
-			M:       migration,
-			Version: migrationVersion,
+type flywayMigrations struct {
+	versioned  SortableMigrations
+	undos      map[string]string
+	repeatable []*flywayRepeatableMigration
+	// versionPaths 和namePaths 按版本号和可重复执行的文件名记录文件路径, 用于检查重复
+	versionPaths map[string][]string
+	namePaths    map[string][]string
+	problems     []FlywayProblem
+}
+
+type flywayRepeatableMigration struct {
+	M           *Migration
+	Description string
+}
+
+func newFlywayMigrations() *flywayMigrations {
+	return &flywayMigrations{
+		versioned:    make(SortableMigrations, 0),
+		undos:        make(map[string]string),
+		versionPaths: make(map[string][]string),
+		namePaths:    make(map[string][]string),
+	}
+}
+
+func (f *flywayMigrations) add(file *flywayFile, filePath string, content string) {
+	if file.Prefix == "R" {
+		f.namePaths[file.Name] = append(f.namePaths[file.Name], filePath)
+	} else {
+		versionKey := file.Prefix + file.Version.String()
+		f.versionPaths[versionKey] = append(f.versionPaths[versionKey], filePath)
+	}
+	switch file.Prefix {
+	case "U":
+		f.undos[file.Version.String()] = content
+	case "R":
+		f.repeatable = append(f.repeatable, &flywayRepeatableMigration{
+			M: &Migration{
+				Name:       file.Name,
+				Content:    content,
+				Repeatable: true,
+			},
+			Description: file.Description,
+		})
+	default:
+		f.versioned = append(f.versioned, &SortableMigration{
+			M: &Migration{
+				Name:    file.Name,
+				Content: content,
+				Version: file.Version.String(),
+			},
+			Version: file.Version,
 		})
+	}
+}
 
+// addFile 解析并添加一个文件, 无效的文件名和看起来像Migration的文件记录为问题
+func (f *flywayMigrations) addFile(fsys fs.FS, filePath string) error {
+	filename := path.Base(filePath)
+	file, err := parseFlywayFilename(filename)
+	if err != nil {
+		f.problems = append(f.problems, FlywayProblem{Kind: FlywayInvalidName, Paths: []string{filePath}, Detail: err.Error()})
 		return nil
-	})
+	}
+	if file == nil {
+		// 回调SQL文件不是Migration, 也不是无效的文件
+		if parseFlywayCallbackFilename(filename) == "" && reFlywayLookAlike.MatchString(filename) {
+			f.problems = append(f.problems, FlywayProblem{Kind: FlywayIgnoredFile, Paths: []string{filePath},
+				Detail: fmt.Sprintf("%s looks like a migration but does not match V<version>__<description>.sql", filename)})
+		}
+		return nil
+	}
+	content, err := fs.ReadFile(fsys, filePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	f.add(file, filePath, string(content))
+	return nil
+}
 
-	sort.Sort(sortableMigrations)
-	migrations := make([]Migration, len(sortableMigrations))
-	for i, sortableMigration := range sortableMigrations {
-		migrations[i] = *sortableMigration.M
+// checkFiles 检查重复的版本号和可重复执行的文件名, 以及没有对应版本的回滚脚本
+func (f *flywayMigrations) checkFiles() []FlywayProblem {
+	problems := make([]FlywayProblem, 0)
+	versions := make(map[string]bool)
+	for _, sortableMigration := range f.versioned {
+		versions[sortableMigration.M.Version] = true
+	}
+	for versionKey, paths := range f.versionPaths {
+		// 回滚脚本只会附加到对应版本的Migration上, 否则会被丢弃
+		if strings.HasPrefix(versionKey, "U") && !versions[strings.TrimPrefix(versionKey, "U")] {
+			for _, undoPath := range paths {
+				problems = append(problems, FlywayProblem{Kind: FlywayIgnoredFile, Paths: []string{undoPath},
+					Detail: fmt.Sprintf("%s is an undo without matching version V%s", path.Base(undoPath), strings.TrimPrefix(versionKey, "U"))})
+			}
+		}
 	}
-	return migrations, nil
+	for versionKey, paths := range f.versionPaths {
+		if len(paths) > 1 {
+			problems = append(problems, FlywayProblem{Kind: FlywayDuplicateVersion, Paths: paths,
+				Detail: fmt.Sprintf("%s is defined %d times", versionKey, len(paths))})
+		}
+	}
+	for _, paths := range f.namePaths {
+		if len(paths) > 1 {
+			problems = append(problems, FlywayProblem{Kind: FlywayDuplicateName, Paths: paths,
+				Detail: fmt.Sprintf("%s is defined %d times", path.Base(paths[0]), len(paths))})
+		}
+	}
+	return problems
 }
 
-func GetMigrationsFromFlywayEmbedFS(embedFS embed.FS, subDirPath string) ([]Migration, error) {
-	entries, err := embedFS.ReadDir(subDirPath)
-	if err != nil {
-		return nil, err
+// migrations 返回按版本排序的Migration, 回滚脚本附加到对应版本上, 可重复执行的Migration按描述排在最后
+func (f *flywayMigrations) migrations() []Migration {
+	sort.Sort(f.versioned)
+	sort.Slice(f.repeatable, func(i, j int) bool {
+		return f.repeatable[i].Description < f.repeatable[j].Description
+	})
+	migrations := make([]Migration, 0, len(f.versioned)+len(f.repeatable))
+	for _, sortableMigration := range f.versioned {
+		migration := *sortableMigration.M
+		migration.DownContent = f.undos[migration.Version]
+		migrations = append(migrations, migration)
+	}
+	for _, repeatableMigration := range f.repeatable {
+		migrations = append(migrations, *repeatableMigration.M)
 	}
+	return migrations
+}
+
+// FlywayLoadOptions 过滤读取的文件, 模式使用path.Match的语法,
+// 包含/的模式匹配相对root的路径, 否则匹配文件名或目录名
+type FlywayLoadOptions struct {
+	// Include 不为空时只读取匹配其中任一模式的文件
+	Include []string
+	// Exclude 跳过匹配其中任一模式的文件和目录
+	Exclude []string
+	// Strict 为true时, 重复的版本号或文件名, 无效的文件名, 看起来像Migration的文件和没有对应版本的回滚脚本
+	// 都会使解析失败并返回*FlywayParseError, 否则这些问题作为警告返回
+	Strict bool
+}
 
-	sortableMigrations := make(SortableMigrations, 0)
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+func (o *FlywayLoadOptions) validate() error {
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: %s", err, pattern)
 		}
+	}
+	return nil
+}
 
-		matches := reValidFlywayFilename.FindStringSubmatch(entry.Name())
-		if len(matches) == 0 {
-			continue
+func matchGlobs(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		name := relPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(relPath)
 		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
 
-		versionStr := matches[1]
-		migrationVersion, err := ParseMigrationVersion(versionStr)
+// walkFlywayFS 递归遍历root下按options过滤后的文件
+func walkFlywayFS(fsys fs.FS, root string, options FlywayLoadOptions, fn func(filePath string) error) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	root = path.Clean(root)
+	return fs.WalkDir(fsys, root, func(filePath string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		entryPath := subDirPath + "/" + entry.Name()
-		if subDirPath == "." {
-			entryPath = entry.Name()
+		relPath := filePath
+		if root != "." {
+			relPath = strings.TrimPrefix(filePath, root+"/")
 		}
-		content, err := embedFS.ReadFile(entryPath)
-		if err != nil {
-			return nil, err
+		if filePath != root && matchGlobs(options.Exclude, relPath) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
 		}
-		migration := &Migration{
-			Name:    matches[0],
-			Content: string(content),
+		if d.IsDir() || (len(options.Include) > 0 && !matchGlobs(options.Include, relPath)) {
+			return nil
 		}
-		sortableMigrations = append(sortableMigrations, &SortableMigration{
-			M:       migration,
-			Version: migrationVersion,
-		})
+		return fn(filePath)
+	})
+}
+
+// GetMigrationsFromFlywayFS 递归读取fsys中root下的Flyway风格Migration, 适用于os.DirFS, embed.FS, zip等任意fs.FS.
+// 非严格模式下忽略发现的问题, 需要警告时使用LoadFlywayMigrations
+func GetMigrationsFromFlywayFS(fsys fs.FS, root string, options FlywayLoadOptions) ([]Migration, error) {
+	migrations, _, err := LoadFlywayMigrations(fsys, root, options)
+	return migrations, err
+}
+
+// LoadFlywayMigrations 与GetMigrationsFromFlywayFS相同, 非严格模式下同时返回发现的问题作为警告
+func LoadFlywayMigrations(fsys fs.FS, root string, options FlywayLoadOptions) (migrations []Migration, warnings []FlywayProblem, err error) {
+	flywayMigrations := newFlywayMigrations()
+	err = walkFlywayFS(fsys, root, options, func(filePath string) error {
+		return flywayMigrations.addFile(fsys, filePath)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	sort.Sort(sortableMigrations)
-	migrations := make([]Migration, len(sortableMigrations))
-	for i, sortableMigration := range sortableMigrations {
-		migrations[i] = *sortableMigration.M
+
+	// 问题按第一个文件的路径排序
+	problems := append(flywayMigrations.problems, flywayMigrations.checkFiles()...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Paths[0] < problems[j].Paths[0]
+	})
+	if options.Strict && len(problems) > 0 {
+		return nil, nil, &FlywayParseError{Problems: problems}
 	}
-	return migrations, nil
-}
\ No newline at end of file
+	return flywayMigrations.migrations(), problems, nil
+}
+
+func GetMigrationsFromFlywayDir(sourcePath string) ([]Migration, error) {
+	return GetMigrationsFromFlywayFS(os.DirFS(sourcePath), ".", FlywayLoadOptions{})
+}
+
+func GetMigrationsFromFlywayEmbedFS(embedFS embed.FS, subDirPath string) ([]Migration, error) {
+	return GetMigrationsFromFlywayFS(embedFS, subDirPath, FlywayLoadOptions{})
+}
@@ -2,10 +2,13 @@ package gomigrate
 
 import (
 	"embed"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 const (
@@ -92,6 +95,9 @@ func TestGetMigrationsFromFlywayDir(t *testing.T) {
 			t.FailNow()
 		}
 	}
+	if migrations[2].Version != "1.2" {
+		t.FailNow()
+	}
 }
 
 func TestGetMigrationsFromFlywayEmbedFS(t *testing.T) {
@@ -109,4 +115,133 @@ func TestGetMigrationsFromFlywayEmbedFS(t *testing.T) {
 			t.FailNow()
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestGetMigrationsFromFlywayEmbedFSWithUndoAndRepeatable(t *testing.T) {
+	migrations, err := GetMigrationsFromFlywayEmbedFS(testdataFS, "testdata/embed_flyway/undo_repeatable")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	names := []string{"V1__test_table1.sql", "V2__test_table2.sql", "r__test_view1.sql", "R__test_view2.sql"}
+	if len(migrations) != len(names) {
+		t.FailNow()
+	}
+	for i, name := range names {
+		if migrations[i].Name != name {
+			t.Errorf("expect %s, got %s", name, migrations[i].Name)
+		}
+	}
+	if migrations[0].DownContent != "drop table test_table1" || migrations[1].DownContent != "" {
+		t.FailNow()
+	}
+	if migrations[1].Repeatable || !migrations[2].Repeatable || !migrations[3].Repeatable {
+		t.FailNow()
+	}
+}
+
+func TestGetMigrationsFromFlywayFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/V1__test_table1.sql":             {Data: []byte("create table test_table1(id int)")},
+		"db/users/V2__test_table2.sql":       {Data: []byte("create table test_table2(id int)")},
+		"db/users/U2__test_table2.sql":       {Data: []byte("drop table test_table2")},
+		"db/orders/V1_1__test_table3.sql":    {Data: []byte("create table test_table3(id int)")},
+		"db/orders/R__test_view1.sql":        {Data: []byte("create or replace view test_view1 as select 1")},
+		"db/legacy/V0_9__test_table4.sql":    {Data: []byte("create table test_table4(id int)")},
+		"db/orders/V3__test_table5.sql.orig": {Data: []byte("create table test_table5(id int)")},
+		"db/seeds/V4__test_seed.sql":         {Data: []byte("insert into test_table1 values(1)")},
+		"other/V5__outside_root.sql":         {Data: []byte("create table test_table6(id int)")},
+	}
+
+	migrations, err := GetMigrationsFromFlywayFS(fsys, "db", FlywayLoadOptions{Exclude: []string{"legacy", "seeds/*"}})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	names := []string{"V1__test_table1.sql", "V1_1__test_table3.sql", "V2__test_table2.sql", "R__test_view1.sql"}
+	if len(migrations) != len(names) {
+		t.Errorf("expect %d migrations, got %v", len(names), migrations)
+		t.FailNow()
+	}
+	for i, name := range names {
+		if migrations[i].Name != name {
+			t.Errorf("expect %s, got %s", name, migrations[i].Name)
+		}
+	}
+	if migrations[2].DownContent != "drop table test_table2" {
+		t.FailNow()
+	}
+
+	migrations, err = GetMigrationsFromFlywayFS(fsys, ".", FlywayLoadOptions{Include: []string{"V*"}, Exclude: []string{"db"}})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrations) != 1 || migrations[0].Name != "V5__outside_root.sql" {
+		t.Errorf("unexpected migrations %v", migrations)
+	}
+
+	if _, err = GetMigrationsFromFlywayFS(fsys, "db", FlywayLoadOptions{Include: []string{"["}}); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestLoadFlywayMigrationsProblems(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/V1__test_table1.sql":          {Data: []byte("create table test_table1(id int)")},
+		"db/U1__test_table1.sql":          {Data: []byte("drop table test_table1")},
+		"db/U5__test_table7.sql":          {Data: []byte("drop table test_table7")},
+		"db/V2__test_table2.sql":          {Data: []byte("create table test_table2(id int)")},
+		"db/users/v2__test_table3.sql":    {Data: []byte("create table test_table3(id int)")},
+		"db/R__test_view1.sql":            {Data: []byte("create or replace view test_view1 as select 1")},
+		"db/users/R__test_view1.sql":      {Data: []byte("create or replace view test_view1 as select 2")},
+		"db/R1__invalid.sql":              {Data: []byte("select 1")},
+		"db/4_1__test_table4.sql":         {Data: []byte("create table test_table4(id int)")},
+		"db/v4.2__test_table5.sql":        {Data: []byte("create table test_table5(id int)")},
+		"db/afterMigrate__refresh.sql":    {Data: []byte("select 1")},
+		"db/schema.sql":                   {Data: []byte("select 1")},
+		"db/V3__test_table6.sql.disabled": {Data: []byte("select 1")},
+	}
+	expected := []FlywayProblem{
+		{Kind: FlywayIgnoredFile, Paths: []string{"db/4_1__test_table4.sql"}},
+		{Kind: FlywayInvalidName, Paths: []string{"db/R1__invalid.sql"}},
+		{Kind: FlywayDuplicateName, Paths: []string{"db/R__test_view1.sql", "db/users/R__test_view1.sql"}},
+		{Kind: FlywayIgnoredFile, Paths: []string{"db/U5__test_table7.sql"}},
+		{Kind: FlywayDuplicateVersion, Paths: []string{"db/V2__test_table2.sql", "db/users/v2__test_table3.sql"}},
+		{Kind: FlywayIgnoredFile, Paths: []string{"db/v4.2__test_table5.sql"}},
+	}
+	checkProblems := func(problems []FlywayProblem) {
+		if len(problems) != len(expected) {
+			t.Errorf("expect %d problems, got %v", len(expected), problems)
+			t.FailNow()
+		}
+		for i := range expected {
+			if problems[i].Kind != expected[i].Kind || strings.Join(problems[i].Paths, ", ") != strings.Join(expected[i].Paths, ", ") {
+				t.Errorf("expect %v, got %v", expected[i], problems[i])
+			}
+		}
+	}
+
+	migrations, warnings, err := LoadFlywayMigrations(fsys, "db", FlywayLoadOptions{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(migrations) != 5 || migrations[0].DownContent != "drop table test_table1" {
+		t.Errorf("unexpected migrations %v", migrations)
+	}
+	checkProblems(warnings)
+
+	_, err = GetMigrationsFromFlywayFS(fsys, "db", FlywayLoadOptions{Strict: true})
+	var parseErr *FlywayParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrInvalidMigrations) {
+		t.Error(err)
+		t.FailNow()
+	}
+	checkProblems(parseErr.Problems)
+
+	_, warnings, err = LoadFlywayMigrations(fsys, "db", FlywayLoadOptions{Strict: true, Include: []string{"V1__*"}})
+	if err != nil || len(warnings) != 0 {
+		t.FailNow()
+	}
+}
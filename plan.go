@@ -0,0 +1,98 @@
+package gomigrate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type MigrationPlan struct {
+	// InitSQL 创建Schema History表的语句, 表已存在时为空
+	InitSQL string
+	// UpgradeSQL 升级旧版本创建的Schema History表的语句, 表不存在或已是最新时为空
+	UpgradeSQL []string
+	Migrations []PlannedMigration
+}
+
+type PlannedMigration struct {
+	Rank        int
+	Name        string
+	ContentHash string
+	SQL         string
+	// HistorySQL 写入Schema History的语句
+	HistorySQL string
+	// Func 为true时是Go函数Migration, 无法输出为SQL
+	Func bool
+}
+
+// WriteSQL 将计划输出为一个可供审阅和手动执行的SQL脚本
+func (p *MigrationPlan) WriteSQL(w io.Writer) error {
+	statements := make([]string, 0)
+	statements = append(statements, fmt.Sprintf("-- %d pending migrations", len(p.Migrations)))
+	if p.InitSQL != "" {
+		statements = append(statements, "-- create schema history table", terminateSQL(p.InitSQL))
+	}
+	if len(p.UpgradeSQL) > 0 {
+		statements = append(statements, "-- upgrade schema history table")
+		for _, upgradeSQL := range p.UpgradeSQL {
+			statements = append(statements, terminateSQL(upgradeSQL))
+		}
+	}
+	for _, migration := range p.Migrations {
+		if migration.Func {
+			return fmt.Errorf("%w: %s", ErrFuncMigrationNotInSQL, migration.Name)
+		}
+	}
+	for _, migration := range p.Migrations {
+		statements = append(statements, fmt.Sprintf("-- rank %d: %s (sha1 %s)", migration.Rank, migration.Name, migration.ContentHash))
+		statements = append(statements, delimitSQLStatements(splitSQLStatements(migration.SQL))...)
+		statements = append(statements, terminateSQL(migration.HistorySQL))
+	}
+	_, err := io.WriteString(w, strings.Join(statements, "\n")+"\n")
+	return err
+}
+
+// delimitSQLStatements 为拆分后的语句加上分隔符, 有语句包含分号(如存储过程)时改用DELIMITER, 之后恢复为分号
+func delimitSQLStatements(statements []sqlStatement) []string {
+	delimiter := ";"
+	for _, statement := range statements {
+		if strings.Contains(statement.SQL, ";") {
+			delimiter = "$$"
+			break
+		}
+	}
+	if delimiter != ";" {
+		for containsDelimiter(statements, delimiter) {
+			delimiter += "$"
+		}
+	}
+
+	lines := make([]string, 0, len(statements)+2)
+	if delimiter != ";" {
+		lines = append(lines, "DELIMITER "+delimiter)
+	}
+	for _, statement := range statements {
+		lines = append(lines, statement.SQL+delimiter)
+	}
+	if delimiter != ";" {
+		lines = append(lines, "DELIMITER ;")
+	}
+	return lines
+}
+
+func containsDelimiter(statements []sqlStatement, delimiter string) bool {
+	for _, statement := range statements {
+		if strings.Contains(statement.SQL, delimiter) {
+			return true
+		}
+	}
+	return false
+}
+
+func terminateSQL(statement string) string {
+	statement = strings.TrimSpace(statement)
+	if !strings.HasSuffix(statement, ";") {
+		statement += ";"
+	}
+	return statement
+}
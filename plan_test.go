@@ -0,0 +1,100 @@
+package gomigrate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMigrationPlanWriteSQL(t *testing.T) {
+	plan := &MigrationPlan{
+		InitSQL: "CREATE TABLE IF NOT EXISTS `gomigrate_schema_history`(`rank` INT(11) NOT NULL);",
+		Migrations: []PlannedMigration{
+			{
+				Rank:        1,
+				Name:        "test_table1",
+				ContentHash: "hash1",
+				SQL:         "create table test_table1(id int)\n",
+				HistorySQL:  "INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(1)",
+			},
+		},
+	}
+	buffer := &bytes.Buffer{}
+	err := plan.WriteSQL(buffer)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := "-- 1 pending migrations\n" +
+		"-- create schema history table\n" +
+		"CREATE TABLE IF NOT EXISTS `gomigrate_schema_history`(`rank` INT(11) NOT NULL);\n" +
+		"-- rank 1: test_table1 (sha1 hash1)\n" +
+		"create table test_table1(id int);\n" +
+		"INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(1);\n"
+	if buffer.String() != expected {
+		t.Error(buffer.String())
+	}
+
+	plan.InitSQL = ""
+	plan.UpgradeSQL = []string{"ALTER TABLE `gomigrate_schema_history` ADD COLUMN `type` VARCHAR(32)"}
+	buffer.Reset()
+	if err = plan.WriteSQL(buffer); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected = "-- 1 pending migrations\n" +
+		"-- upgrade schema history table\n" +
+		"ALTER TABLE `gomigrate_schema_history` ADD COLUMN `type` VARCHAR(32);\n" +
+		"-- rank 1: test_table1 (sha1 hash1)\n" +
+		"create table test_table1(id int);\n" +
+		"INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(1);\n"
+	if buffer.String() != expected {
+		t.Error(buffer.String())
+	}
+}
+
+func TestMigrationPlanWriteSQLWithCommentsAndDelimiter(t *testing.T) {
+	plan := &MigrationPlan{
+		Migrations: []PlannedMigration{
+			{
+				Rank:        1,
+				Name:        "test_table1",
+				ContentHash: "hash1",
+				SQL:         "create table test_table1(id int); -- trailing comment\ninsert into test_table1 values(1) # done\n",
+				HistorySQL:  "INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(1)",
+			},
+			{
+				Rank:        2,
+				Name:        "test_trigger",
+				ContentHash: "hash2",
+				SQL: "DELIMITER //\n" +
+					"create trigger test_trigger before insert on test_table1 for each row begin set new.id = new.id + 1; end//\n",
+				HistorySQL: "INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(2)",
+			},
+		},
+	}
+	buffer := &bytes.Buffer{}
+	err := plan.WriteSQL(buffer)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := "-- 2 pending migrations\n" +
+		"-- rank 1: test_table1 (sha1 hash1)\n" +
+		"create table test_table1(id int);\n" +
+		"insert into test_table1 values(1);\n" +
+		"INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(1);\n" +
+		"-- rank 2: test_trigger (sha1 hash2)\n" +
+		"DELIMITER $$\n" +
+		"create trigger test_trigger before insert on test_table1 for each row begin set new.id = new.id + 1; end$$\n" +
+		"DELIMITER ;\n" +
+		"INSERT INTO `gomigrate_schema_history`(`rank`) VALUES(2);\n"
+	if buffer.String() != expected {
+		t.Error(buffer.String())
+	}
+
+	// 输出的脚本按相同的规则拆分后与原来的语句一致
+	statements := splitSQLStatements(buffer.String())
+	if len(statements) != 5 || statements[3].SQL != "create trigger test_trigger before insert on test_table1 for each row begin set new.id = new.id + 1; end" {
+		t.Errorf("%+v", statements)
+	}
+}
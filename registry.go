@@ -0,0 +1,82 @@
+package gomigrate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type migrationRegistry struct {
+	mu         sync.Mutex
+	migrations SortableMigrations
+}
+
+var defaultRegistry = &migrationRegistry{}
+
+// Register 注册Go函数Migration, 通常在各个包的init()中调用, down可为空.
+// 注册的Migration以name作为校验值, 修改了up的行为不会被发现, 需要发现时使用RegisterWithChecksum.
+// version无法解析, 或version, name重复时panic
+func Register(version string, name string, up MigrationFunc, down MigrationFunc) {
+	RegisterWithChecksum(version, name, name, up, down)
+}
+
+// RegisterWithChecksum 与Register相同, 但以checksum作为校验值(即FuncChecksum), 修改up的行为时同时修改checksum,
+// 已安装的Migration会变为MIGRATION MODIFIED. checksum为空时panic
+func RegisterWithChecksum(version string, name string, checksum string, up MigrationFunc, down MigrationFunc) {
+	if err := defaultRegistry.register(version, name, checksum, up, down); err != nil {
+		panic(err)
+	}
+}
+
+// Registered 返回所有注册的Migration, 与Flyway风格的Migration一样按版本排序, 可直接用于SetMigrations
+func Registered() []Migration {
+	return defaultRegistry.registered()
+}
+
+func (r *migrationRegistry) register(version string, name string, checksum string, up MigrationFunc, down MigrationFunc) error {
+	if up == nil {
+		return fmt.Errorf("%w: %s has no up function", ErrInvalidMigrations, name)
+	}
+	if checksum == "" {
+		return fmt.Errorf("%w: %s has no checksum", ErrInvalidMigrations, name)
+	}
+	migrationVersion, err := ParseMigrationVersion(version)
+	if err != nil {
+		return fmt.Errorf("%w: %s has invalid version %s", ErrInvalidMigrations, name, version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, registered := range r.migrations {
+		if registered.M.Name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicatedMigrationName, name)
+		}
+		if MigrationVersion(registered.Version).Compare(migrationVersion) == 0 {
+			return fmt.Errorf("%w: %s of %s and %s", ErrDuplicatedMigrationVersion, version, registered.M.Name, name)
+		}
+	}
+	r.migrations = append(r.migrations, &SortableMigration{
+		M: &Migration{
+			Name:         name,
+			Version:      migrationVersion.String(),
+			UpFunc:       up,
+			DownFunc:     down,
+			FuncChecksum: checksum,
+		},
+		Version: migrationVersion,
+	})
+	return nil
+}
+
+func (r *migrationRegistry) registered() []Migration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sortableMigrations := make(SortableMigrations, len(r.migrations))
+	copy(sortableMigrations, r.migrations)
+	sort.Stable(sortableMigrations)
+	migrations := make([]Migration, len(sortableMigrations))
+	for i, sortableMigration := range sortableMigrations {
+		migrations[i] = *sortableMigration.M
+	}
+	return migrations
+}
@@ -0,0 +1,49 @@
+package gomigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestMigrationRegistry(t *testing.T) {
+	up := func(ctx context.Context, tx *sql.Tx) error { return nil }
+	registry := &migrationRegistry{}
+	for _, version := range []string{"2", "1.1", "10", "1"} {
+		if err := registry.register(version, "migration_"+version, "migration_"+version, up, nil); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	if err := registry.register("2", "another_migration_2", "another_migration_2", up, nil); !errors.Is(err, ErrDuplicatedMigrationVersion) {
+		t.FailNow()
+	}
+	if err := registry.register("1_1_1", "migration_1_1_1", "v2", up, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := registry.register("3", "migration_2", "migration_2", up, nil); !errors.Is(err, ErrDuplicatedMigrationName) {
+		t.FailNow()
+	}
+	if err := registry.register("v4", "migration_4", "migration_4", up, nil); !errors.Is(err, ErrInvalidMigrations) {
+		t.FailNow()
+	}
+	if err := registry.register("4", "migration_4", "", up, nil); !errors.Is(err, ErrInvalidMigrations) {
+		t.FailNow()
+	}
+
+	migrations := registry.registered()
+	expectedNames := []string{"migration_1", "migration_1.1", "migration_1_1_1", "migration_2", "migration_10"}
+	if len(migrations) != len(expectedNames) {
+		t.FailNow()
+	}
+	for i, name := range expectedNames {
+		if migrations[i].Name != name || !migrations[i].IsFunc() || migrations[i].FuncChecksum == "" {
+			t.Errorf("expect migration %d to be %s, got %s", i, name, migrations[i].Name)
+		}
+	}
+	if migrations[2].FuncChecksum != "v2" || migrations[3].FuncChecksum != "migration_2" {
+		t.FailNow()
+	}
+}
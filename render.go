@@ -0,0 +1,339 @@
+package gomigrate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+type OutputFormat string
+
+const (
+	OutputTable    OutputFormat = "table"
+	OutputJSON     OutputFormat = "json"
+	OutputYAML     OutputFormat = "yaml"
+	OutputMarkdown OutputFormat = "markdown"
+	OutputCSV      OutputFormat = "csv"
+)
+
+const installedTimeLayout = "2006-01-02 15:04:05"
+
+// ParseOutputFormat 解析命令行等场景传入的输出格式, 忽略大小写
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	format := OutputFormat(strings.ToLower(strings.TrimSpace(s)))
+	switch format {
+	case "":
+		return OutputTable, nil
+	case OutputTable, OutputJSON, OutputYAML, OutputMarkdown, OutputCSV:
+		return format, nil
+	case "yml":
+		return OutputYAML, nil
+	case "md":
+		return OutputMarkdown, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownOutputFormat, s)
+}
+
+type RenderOptions struct {
+	Format OutputFormat
+	// HistoryDetails 为true时同时输出执行耗时, 执行者, 应用版本和是否成功
+	HistoryDetails bool
+}
+
+// migrationStatusReport 是JSON和YAML输出的结构, rank和installed_time为null表示没有对应的Schema History
+type migrationStatusReport struct {
+	Migrations []migrationStatusRow `json:"migrations"`
+	Hints      []string             `json:"hints"`
+}
+
+type migrationStatusRow struct {
+	Rank          *int    `json:"rank"`
+	SchemaName    string  `json:"schema_name"`
+	MigrationName string  `json:"migration_name"`
+	InstalledTime *string `json:"installed_time"`
+	Status        string  `json:"status"`
+
+	ExecutionTimeMs *int64  `json:"execution_time_ms,omitempty"`
+	InstalledBy     *string `json:"installed_by,omitempty"`
+	InstalledHost   *string `json:"installed_host,omitempty"`
+	AppVersion      *string `json:"app_version,omitempty"`
+	Success         *bool   `json:"success,omitempty"`
+}
+
+func newMigrationStatusReport(migrateInfos []MigrationInfo, historyDetails bool) *migrationStatusReport {
+	report := &migrationStatusReport{
+		Migrations: make([]migrationStatusRow, 0, len(migrateInfos)),
+		Hints:      migrationHints(migrateInfos),
+	}
+	for _, migrateInfo := range migrateInfos {
+		row := migrationStatusRow{
+			SchemaName:    migrateInfo.SchemaName,
+			MigrationName: migrateInfo.MigrationName,
+			Status:        migrateInfo.Status.String(),
+		}
+		if migrateInfo.SchemaHistory != nil {
+			rank := migrateInfo.Rank
+			installedTime := migrateInfo.InstalledTime.Format(time.RFC3339)
+			row.Rank = &rank
+			row.InstalledTime = &installedTime
+			if historyDetails {
+				schemaHistory := migrateInfo.SchemaHistory
+				executionTimeMs := schemaHistory.ExecutionTime.Milliseconds()
+				row.ExecutionTimeMs = &executionTimeMs
+				row.InstalledBy = &schemaHistory.InstalledBy
+				row.InstalledHost = &schemaHistory.InstalledHost
+				row.AppVersion = &schemaHistory.AppVersion
+				row.Success = &schemaHistory.Success
+			}
+		}
+		report.Migrations = append(report.Migrations, row)
+	}
+	return report
+}
+
+// RenderMigrationInfos 按指定格式输出GetMigrationInfos的结果以及修复提示, 各格式包含相同的行和提示.
+// 表格格式只在w为终端时使用颜色
+func RenderMigrationInfos(w io.Writer, migrateInfos []MigrationInfo, options RenderOptions) error {
+	switch options.Format {
+	case "", OutputTable:
+		return renderTable(w, migrateInfos, options.HistoryDetails, isTerminal(w))
+	case OutputMarkdown:
+		return renderMarkdown(w, migrateInfos, options.HistoryDetails)
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(newMigrationStatusReport(migrateInfos, options.HistoryDetails))
+	case OutputYAML:
+		return renderYAML(w, newMigrationStatusReport(migrateInfos, options.HistoryDetails))
+	case OutputCSV:
+		return renderCSV(w, newMigrationStatusReport(migrateInfos, options.HistoryDetails), options.HistoryDetails)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownOutputFormat, options.Format)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+func newMigrationTable(migrateInfos []MigrationInfo, historyDetails bool, colored bool) table.Writer {
+	t := table.NewWriter()
+	header := table.Row{"Rank", "Schema Name", "Migration Name", "Installed Time", "Status"}
+	if historyDetails {
+		header = append(header, "Execution Time", "Installed By", "App Version", "Success")
+	}
+	t.AppendHeader(header)
+
+	for _, migrateInfo := range migrateInfos {
+		rankText := "-"
+		installedTimeText := "-"
+		if migrateInfo.SchemaHistory != nil {
+			rankText = fmt.Sprint(migrateInfo.Rank)
+			installedTimeText = migrateInfo.InstalledTime.Format(installedTimeLayout)
+		}
+
+		statusText := migrateInfo.Status.String()
+		if colored {
+			statusColor := text.FgGreen
+			if isErrorStatus(migrateInfo.Status) {
+				statusColor = text.FgRed
+			}
+			statusText = statusColor.Sprint(statusText)
+		}
+		row := table.Row{rankText, migrateInfo.SchemaName, migrateInfo.MigrationName, installedTimeText, statusText}
+		if historyDetails {
+			row = append(row, "-", "", "", "-")
+			if schemaHistory := migrateInfo.SchemaHistory; schemaHistory != nil {
+				installedBy := schemaHistory.InstalledBy
+				if schemaHistory.InstalledHost != "" {
+					installedBy += "@" + schemaHistory.InstalledHost
+				}
+				row[5] = schemaHistory.ExecutionTime.String()
+				row[6] = installedBy
+				row[7] = schemaHistory.AppVersion
+				row[8] = strconv.FormatBool(schemaHistory.Success)
+			}
+		}
+		t.AppendRow(row)
+	}
+	return t
+}
+
+func renderTable(w io.Writer, migrateInfos []MigrationInfo, historyDetails bool, colored bool) error {
+	helpTips := "all is well"
+	if hints := migrationHints(migrateInfos); len(hints) > 0 {
+		for i := range hints {
+			hints[i] = fmt.Sprintf("(%d) %s", i+1, hints[i])
+		}
+		helpTips = strings.Join(hints, "\n")
+	}
+
+	_, err := fmt.Fprintf(w, "%s\n%s\n", newMigrationTable(migrateInfos, historyDetails, colored).Render(), helpTips)
+	return err
+}
+
+func renderMarkdown(w io.Writer, migrateInfos []MigrationInfo, historyDetails bool) error {
+	helpTips := "all is well"
+	if hints := migrationHints(migrateInfos); len(hints) > 0 {
+		for i := range hints {
+			hints[i] = fmt.Sprintf("%d. %s", i+1, hints[i])
+		}
+		helpTips = strings.Join(hints, "\n")
+	}
+
+	_, err := fmt.Fprintf(w, "%s\n\n%s\n", newMigrationTable(migrateInfos, historyDetails, false).RenderMarkdown(), helpTips)
+	return err
+}
+
+// renderCSV 提示以#开头的注释行写在最后, 可以用Comment为'#'的csv.Reader读取
+func renderCSV(w io.Writer, report *migrationStatusReport, historyDetails bool) error {
+	csvWriter := csv.NewWriter(w)
+	header := []string{"rank", "schema_name", "migration_name", "installed_time", "status"}
+	if historyDetails {
+		header = append(header, "execution_time_ms", "installed_by", "installed_host", "app_version", "success")
+	}
+	err := csvWriter.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, row := range report.Migrations {
+		rankText := ""
+		installedTimeText := ""
+		if row.Rank != nil {
+			rankText = strconv.Itoa(*row.Rank)
+			installedTimeText = *row.InstalledTime
+		}
+		record := []string{rankText, row.SchemaName, row.MigrationName, installedTimeText, row.Status}
+		if historyDetails {
+			record = append(record, "", "", "", "", "")
+			if row.Rank != nil {
+				record[5] = strconv.FormatInt(*row.ExecutionTimeMs, 10)
+				record[6] = *row.InstalledBy
+				record[7] = *row.InstalledHost
+				record[8] = *row.AppVersion
+				record[9] = strconv.FormatBool(*row.Success)
+			}
+		}
+		err = csvWriter.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return err
+	}
+
+	for _, hint := range report.Hints {
+		if _, err = fmt.Fprintf(w, "# %s\n", hint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderYAML(w io.Writer, report *migrationStatusReport) error {
+	var builder strings.Builder
+	if len(report.Migrations) == 0 {
+		builder.WriteString("migrations: []\n")
+	} else {
+		builder.WriteString("migrations:\n")
+	}
+	for _, row := range report.Migrations {
+		rankText := "null"
+		installedTimeText := "null"
+		if row.Rank != nil {
+			rankText = strconv.Itoa(*row.Rank)
+			installedTimeText = yamlString(*row.InstalledTime)
+		}
+		fmt.Fprintf(&builder, "  - rank: %s\n", rankText)
+		fmt.Fprintf(&builder, "    schema_name: %s\n", yamlString(row.SchemaName))
+		fmt.Fprintf(&builder, "    migration_name: %s\n", yamlString(row.MigrationName))
+		fmt.Fprintf(&builder, "    installed_time: %s\n", installedTimeText)
+		fmt.Fprintf(&builder, "    status: %s\n", yamlString(row.Status))
+		if row.ExecutionTimeMs != nil {
+			fmt.Fprintf(&builder, "    execution_time_ms: %d\n", *row.ExecutionTimeMs)
+			fmt.Fprintf(&builder, "    installed_by: %s\n", yamlString(*row.InstalledBy))
+			fmt.Fprintf(&builder, "    installed_host: %s\n", yamlString(*row.InstalledHost))
+			fmt.Fprintf(&builder, "    app_version: %s\n", yamlString(*row.AppVersion))
+			fmt.Fprintf(&builder, "    success: %t\n", *row.Success)
+		}
+	}
+	if len(report.Hints) == 0 {
+		builder.WriteString("hints: []\n")
+	} else {
+		builder.WriteString("hints:\n")
+	}
+	for _, hint := range report.Hints {
+		fmt.Fprintf(&builder, "  - %s\n", yamlString(hint))
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// yamlString 使用双引号, JSON字符串同时也是合法的YAML字符串
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func isErrorStatus(status MigrateStatus) bool {
+	switch status {
+	case StatusInstalled, StatusReadyToInstall, StatusOutdated, StatusSuperseded, StatusBaseline, StatusAboveTarget, StatusUndone, StatusDeleted:
+		return false
+	}
+	return true
+}
+
+// migrationHints 根据异常状态给出修复建议, 没有异常时返回空
+func migrationHints(migrateInfos []MigrationInfo) []string {
+	hasMissingMigration := false
+	hasModifiedMigration := false
+	hasBrokenSchema := false
+	hasFailedMigration := false
+	for _, migrateInfo := range migrateInfos {
+		switch migrateInfo.Status {
+		case StatusMigrationMissing:
+			hasMissingMigration = true
+		case StatusMigrationModified:
+			hasModifiedMigration = true
+		case StatusBrokenSchemaHistory:
+			hasBrokenSchema = true
+		case StatusFailed:
+			hasFailedMigration = true
+		}
+	}
+
+	hints := make([]string, 0)
+	if hasMissingMigration {
+		hints = append(hints, "to fix MIGRATION MISSING: provide the missing migrations")
+	}
+	if hasModifiedMigration {
+		hints = append(hints, "to fix installed MIGRATION MODIFIED: recovery the installed but modified migrations, "+
+			"or accept the modification with Repair. Please DO NOT modify installed migrations")
+	}
+	if hasBrokenSchema {
+		hints = append(hints, "to fix SCHEMA BROKEN: run Repair to renumber the schema history")
+	}
+	if hasFailedMigration {
+		hints = append(hints, "to fix FAILED: resume from the failed statement with ResumeFailedMigration, "+
+			"or MarkFailedMigrationFixed if you finished it by hand")
+	}
+	return hints
+}
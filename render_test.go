@@ -0,0 +1,141 @@
+package gomigrate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateStatusString(t *testing.T) {
+	if StatusReadyToInstall.String() != "READY TO INSTALL" || StatusBrokenSchemaHistory.String() != "SCHEMA BROKEN" {
+		t.FailNow()
+	}
+	if MigrateStatus(0).String() != "UNKNOWN" || MigrateStatus(100).String() != "UNKNOWN" {
+		t.FailNow()
+	}
+}
+
+func TestRenderMigrationInfos(t *testing.T) {
+	migrations := []Migration{
+		{Name: "test_table1", Content: "content1"},
+		{Name: "test_table2", Content: "content2"},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "test_table1", Content: "content1"}, Rank: 1, InstalledTime: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), Success: true},
+	}
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+	if migrateInfos[0].Rank != 1 || migrateInfos[0].SchemaName != "test_table1" || migrateInfos[1].MigrationName != "test_table2" {
+		t.FailNow()
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputTable}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	output := buffer.String()
+	for _, s := range []string{"2021-01-02 03:04:05", "INSTALLED", "READY TO INSTALL", "all is well"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expect %q in output:\n%s", s, output)
+		}
+	}
+
+	// 异常状态给出修复提示
+	migrateInfos = buildMigrateInfos(schemaHistories, nil, nil)
+	buffer.Reset()
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputTable}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !strings.Contains(buffer.String(), "to fix MIGRATION MISSING") {
+		t.FailNow()
+	}
+}
+
+func TestRenderMigrationInfosFormats(t *testing.T) {
+	migrations := []Migration{
+		{Name: "test_table1", Content: "content1 modified"},
+		{Name: "test_table2", Content: "content2"},
+	}
+	schemaHistories := []SchemaHistory{
+		{Migration: Migration{Name: "test_table1", Content: "content1"}, Rank: 1, InstalledTime: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), Success: true},
+	}
+	migrateInfos := buildMigrateInfos(schemaHistories, migrations, nil)
+
+	// 输出不是终端时不带颜色
+	buffer := &bytes.Buffer{}
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputTable}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if strings.Contains(buffer.String(), "\x1b[") {
+		t.FailNow()
+	}
+
+	buffer.Reset()
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputJSON}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	report := migrationStatusReport{}
+	if err := json.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(report.Migrations) != 2 || len(report.Hints) != 1 || report.Migrations[0].Status != "MIGRATION MODIFIED" {
+		t.FailNow()
+	}
+	if *report.Migrations[0].Rank != 1 || report.Migrations[1].Rank != nil || report.Migrations[1].InstalledTime != nil {
+		t.FailNow()
+	}
+
+	buffer.Reset()
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputCSV}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !strings.Contains(buffer.String(), "# to fix installed MIGRATION MODIFIED") {
+		t.FailNow()
+	}
+	csvReader := csv.NewReader(buffer)
+	csvReader.Comment = '#'
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(records) != 3 || records[1][4] != "MIGRATION MODIFIED" || records[2][0] != "" {
+		t.FailNow()
+	}
+
+	buffer.Reset()
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputYAML}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, s := range []string{"  - rank: 1\n", "  - rank: null\n", `    status: "MIGRATION MODIFIED"`, "hints:\n  - \"to fix"} {
+		if !strings.Contains(buffer.String(), s) {
+			t.Errorf("expect %q in output:\n%s", s, buffer.String())
+		}
+	}
+
+	buffer.Reset()
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: OutputMarkdown}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !strings.Contains(buffer.String(), "| 1 | test_table1 | test_table1 |") || !strings.Contains(buffer.String(), "1. to fix") {
+		t.Errorf("unexpected markdown output:\n%s", buffer.String())
+	}
+
+	if err := RenderMigrationInfos(buffer, migrateInfos, RenderOptions{Format: "xml"}); !errors.Is(err, ErrUnknownOutputFormat) {
+		t.FailNow()
+	}
+	if format, err := ParseOutputFormat("YML"); err != nil || format != OutputYAML {
+		t.FailNow()
+	}
+}
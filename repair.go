@@ -0,0 +1,109 @@
+package gomigrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+type RepairOptions struct {
+	// DryRun 只生成报告, 不修改Schema History
+	DryRun bool
+	// AcceptModified 接受修改的Migration名称或版本号, 其记录会与当前Migration对齐
+	AcceptModified []string
+}
+
+type RepairReport struct {
+	DryRun     bool
+	Removed    []SchemaHistory
+	Renumbered []RenumberedSchemaHistory
+	Realigned  []RealignedSchemaHistory
+	// Modified 仍未被接受的修改过的Migration
+	Modified []string
+}
+
+type RenumberedSchemaHistory struct {
+	Name    string
+	OldRank int
+	NewRank int
+}
+
+type RealignedSchemaHistory struct {
+	Rank      int
+	Name      string
+	Migration Migration
+}
+
+func (r *RepairReport) IsEmpty() bool {
+	return len(r.Removed) == 0 && len(r.Renumbered) == 0 && len(r.Realigned) == 0
+}
+
+func (r *RepairReport) String() string {
+	lines := make([]string, 0)
+	title := "repair report"
+	if r.DryRun {
+		title += " (dry run)"
+	}
+	lines = append(lines, title+":")
+	for _, schemaHistory := range r.Removed {
+		lines = append(lines, fmt.Sprintf("removed failed schema history: rank %d %s", schemaHistory.Rank, schemaHistory.Name))
+	}
+	for _, renumbered := range r.Renumbered {
+		lines = append(lines, fmt.Sprintf("renumbered schema history: rank %d -> %d %s", renumbered.OldRank, renumbered.NewRank, renumbered.Name))
+	}
+	for _, realigned := range r.Realigned {
+		lines = append(lines, fmt.Sprintf("realigned schema history: rank %d %s -> %s", realigned.Rank, realigned.Name, realigned.Migration.Name))
+	}
+	if r.IsEmpty() {
+		lines = append(lines, "nothing to repair")
+	}
+	for _, name := range r.Modified {
+		lines = append(lines, fmt.Sprintf("MIGRATION MODIFIED not accepted: %s", name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// planRepair 计算修复内容: 删除失败的记录, 重新编号rank以消除空缺, 对齐被接受修改的Migration.
+// keepRanks为true时与Flyway的repair一样保留rank的空缺, 不重新编号
+func planRepair(schemaHistories []SchemaHistory, migrations []Migration, build migrateInfoBuilder, options RepairOptions, keepRanks bool) *RepairReport {
+	report := &RepairReport{DryRun: options.DryRun}
+	repairedHistories := make([]SchemaHistory, 0, len(schemaHistories))
+	for _, schemaHistory := range schemaHistories {
+		if !schemaHistory.Success {
+			report.Removed = append(report.Removed, schemaHistory)
+			continue
+		}
+		newRank := len(repairedHistories) + 1
+		if !keepRanks && schemaHistory.Rank != newRank {
+			report.Renumbered = append(report.Renumbered, RenumberedSchemaHistory{
+				Name:    schemaHistory.Name,
+				OldRank: schemaHistory.Rank,
+				NewRank: newRank,
+			})
+			schemaHistory.Rank = newRank
+		}
+		repairedHistories = append(repairedHistories, schemaHistory)
+	}
+
+	for _, migrateInfo := range build(repairedHistories, migrations) {
+		if migrateInfo.Status != StatusMigrationModified {
+			continue
+		}
+		accepted := false
+		for _, target := range options.AcceptModified {
+			if matchMigration(migrateInfo.Migration, target) || migrateInfo.SchemaHistory.Name == target {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			report.Modified = append(report.Modified, migrateInfo.SchemaHistory.Name)
+			continue
+		}
+		report.Realigned = append(report.Realigned, RealignedSchemaHistory{
+			Rank:      migrateInfo.SchemaHistory.Rank,
+			Name:      migrateInfo.SchemaHistory.Name,
+			Migration: *migrateInfo.Migration,
+		})
+	}
+	return report
+}
@@ -0,0 +1,115 @@
+package gomigrate
+
+import "strings"
+
+type sqlStatement struct {
+	SQL string
+	// Line 为语句在Migration中的起始行号, 从1开始
+	Line int
+}
+
+// splitSQLStatements 按MySQL客户端的规则拆分Migration中的语句:
+// 忽略引号, 反引号和注释中的分隔符, 支持DELIMITER修改分隔符, 去掉注释和空语句.
+// /*! */ 和 /*+ */ 会被MySQL执行, 保留在语句中
+func splitSQLStatements(content string) []sqlStatement {
+	statements := make([]sqlStatement, 0)
+	delimiter := ";"
+	var builder strings.Builder
+	line := 1
+	statementLine := 0
+
+	flush := func() {
+		statement := strings.TrimSpace(builder.String())
+		if statement != "" {
+			statements = append(statements, sqlStatement{SQL: statement, Line: statementLine})
+		}
+		builder.Reset()
+		statementLine = 0
+	}
+	// write 写入语句内容, 记录语句的起始行号
+	write := func(s string) {
+		if statementLine == 0 && strings.TrimSpace(s) != "" {
+			statementLine = line
+		}
+		builder.WriteString(s)
+		line += strings.Count(s, "\n")
+	}
+
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		if statementLine == 0 && isDelimiterCommand(rest) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			if newDelimiter := strings.TrimSpace(rest[len("delimiter"):end]); newDelimiter != "" {
+				delimiter = newDelimiter
+			}
+			builder.Reset()
+			i += end
+			continue
+		}
+		if strings.HasPrefix(rest, delimiter) {
+			flush()
+			i += len(delimiter)
+			continue
+		}
+
+		switch c := rest[0]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := quotedLength(rest)
+			write(rest[:end])
+			i += end
+		case c == '#' || isDashComment(rest):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+			if strings.HasPrefix(rest, "/*!") || strings.HasPrefix(rest, "/*+") {
+				write(rest[:end])
+			} else {
+				// 注释可能分隔了两个单词, 用空格代替
+				line += strings.Count(rest[:end], "\n")
+				builder.WriteByte(' ')
+			}
+			i += end
+		default:
+			write(rest[:1])
+			i++
+		}
+	}
+	flush()
+	return statements
+}
+
+// quotedLength 返回以引号开头的字符串的长度, 反引号中的反斜杠不是转义符
+func quotedLength(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\\' && quote != '`' {
+			i++
+		} else if s[i] == quote {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// isDashComment MySQL中 -- 之后必须是空白字符才是注释
+func isDashComment(s string) bool {
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || s[2] == ' ' || s[2] == '\t' || s[2] == '\r' || s[2] == '\n')
+}
+
+// isDelimiterCommand 判断是否为mysql客户端的DELIMITER命令
+func isDelimiterCommand(s string) bool {
+	return len(s) > len("delimiter") && strings.EqualFold(s[:len("delimiter")], "delimiter") &&
+		(s[len("delimiter")] == ' ' || s[len("delimiter")] == '\t')
+}
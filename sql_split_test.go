@@ -0,0 +1,35 @@
+package gomigrate
+
+import "testing"
+
+func TestSplitSQLStatements(t *testing.T) {
+	content := "-- create table\n" +
+		"create table t1(data varchar(10) default 'a;b'); # comment;\n" +
+		"insert into t1 values(\"it\\\"s;\"), ('it''s');; \n" +
+		"/* multi-line;\ncomment */ select `a;b` /*!40101 , 1 */ from t1;\n" +
+		"select 1--1;\n" +
+		"DELIMITER $$\n" +
+		"create trigger tr1 before insert on t1 for each row begin\n" +
+		"  set new.data = 'x';\n" +
+		"end$$\n" +
+		"delimiter ;\n" +
+		"drop table t1"
+	expected := []sqlStatement{
+		{"create table t1(data varchar(10) default 'a;b')", 2},
+		{"insert into t1 values(\"it\\\"s;\"), ('it''s')", 3},
+		{"select `a;b` /*!40101 , 1 */ from t1", 5},
+		{"select 1--1", 6},
+		{"create trigger tr1 before insert on t1 for each row begin\n  set new.data = 'x';\nend", 8},
+		{"drop table t1", 12},
+	}
+	statements := splitSQLStatements(content)
+	if len(statements) != len(expected) {
+		t.Errorf("expect %d statements, got %q", len(expected), statements)
+		t.FailNow()
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("expect %q at line %d, got %q at line %d", expected[i].SQL, expected[i].Line, statements[i].SQL, statements[i].Line)
+		}
+	}
+}
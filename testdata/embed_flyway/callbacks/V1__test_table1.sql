@@ -0,0 +1 @@
+create table if not exists test_table1(id int)
\ No newline at end of file
@@ -0,0 +1 @@
+insert into test_callback values('afterMigrate')
\ No newline at end of file
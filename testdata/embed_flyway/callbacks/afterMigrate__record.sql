@@ -0,0 +1 @@
+insert into test_callback values('afterMigrate__record')
\ No newline at end of file
@@ -0,0 +1 @@
+create table if not exists test_callback(event varchar(64) not null)
\ No newline at end of file
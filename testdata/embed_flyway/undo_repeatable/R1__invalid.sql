@@ -0,0 +1 @@
+not a migration
\ No newline at end of file
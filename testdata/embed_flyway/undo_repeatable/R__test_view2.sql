@@ -0,0 +1 @@
+create or replace view test_view2 as select id from test_table2
\ No newline at end of file
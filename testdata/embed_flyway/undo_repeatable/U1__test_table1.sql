@@ -0,0 +1 @@
+drop table test_table1
\ No newline at end of file
@@ -0,0 +1 @@
+create table if not exists test_table2(id int unsigned not null auto_increment, primary key(id))
\ No newline at end of file
@@ -0,0 +1 @@
+create or replace view test_view1 as select id from test_table1
\ No newline at end of file